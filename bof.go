@@ -12,14 +12,33 @@ type bof struct {
 	Size uint16
 }
 
-//read the utf16 string from reader
+//read the utf16 string from reader, the count includes the terminating zero
 func (b *bof) utf16String(buf io.ReadSeeker, count uint32) (string, error) {
+	if int64(count)*2 > remaining(buf) {
+		return "", io.ErrUnexpectedEOF
+	}
 	var bts = make([]uint16, count)
 	if err := binary.Read(buf, binary.LittleEndian, &bts); err != nil {
 		return "", err
 	}
-	runes := utf16.Decode(bts[:len(bts)-1])
-	return string(runes), nil
+	if count > 0 {
+		bts = bts[:count-1]
+	}
+	return string(utf16.Decode(bts)), nil
+}
+
+// remaining returns the number of bytes left in the reader of a record
+func remaining(buf io.Seeker) int64 {
+	cur, err := buf.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	end, err := buf.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+	buf.Seek(cur, io.SeekStart)
+	return end - cur
 }
 
 type biffHeader struct {
@@ -0,0 +1,214 @@
+package xls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// CellKind is the type of the value held by a cell
+type CellKind int
+
+// the kinds of cell values
+const (
+	CellBlank CellKind = iota
+	CellNumber
+	CellString
+	CellBool
+	CellError
+	CellDate
+	CellFormula
+)
+
+var cellKindNames = [...]string{
+	CellBlank:   "blank",
+	CellNumber:  "number",
+	CellString:  "string",
+	CellBool:    "bool",
+	CellError:   "error",
+	CellDate:    "date",
+	CellFormula: "formula",
+}
+
+func (k CellKind) String() string {
+	if k >= 0 && int(k) < len(cellKindNames) {
+		return cellKindNames[k]
+	}
+	return fmt.Sprintf("CellKind(%d)", int(k))
+}
+
+// ErrCellType is returned by the typed accessors of Cell when the value has another type
+var ErrCellType = errors.New("xls: wrong cell type")
+
+// Cell is the typed value of one cell, use Row.Cell to get it.
+// For formula cells the accessors work on the result Excel cached when the file was saved.
+type Cell struct {
+	kind CellKind
+	// the kind of the cached result of a formula cell, the same as kind otherwise
+	result   CellKind
+	xf       uint16
+	num      float64
+	str      string
+	text     string
+	color    string
+	date1904 bool
+	formula  string
+	shared   *sharedFormula
+	runs     []TextRun
+	phonetic string
+	// the text Excel shows if it differs from text, like a number in General format
+	display string
+	// the workbook of the cell, set by Row.Cell
+	wb *WorkBook
+}
+
+// Kind returns the type of the cell
+func (c Cell) Kind() CellKind {
+	return c.kind
+}
+
+// ResultKind returns the type of the value, for formula cells the type of the cached result
+func (c Cell) ResultKind() CellKind {
+	return c.result
+}
+
+// XF returns the index of the cell's XF record
+func (c Cell) XF() uint16 {
+	return c.xf
+}
+
+// Style returns the formatting of the cell, false if its XF record is missing
+func (c Cell) Style() (Style, bool) {
+	if c.wb == nil {
+		return Style{}, false
+	}
+	return c.wb.Style(c.xf)
+}
+
+// Font returns the font of the cell, nil if its XF record or font is missing
+func (c Cell) Font() *Font {
+	style, ok := c.Style()
+	if !ok {
+		return nil
+	}
+	return c.wb.Font(style.FontIndex)
+}
+
+// String returns the value as Row.Col shows it
+func (c Cell) String() string {
+	return c.text
+}
+
+// Text returns the value as Excel shows it, which differs from String for numbers in General format:
+// Excel shows at most 11 characters, so 123456789012 is shown as 1.23457E+11
+func (c Cell) Text() string {
+	if c.display != "" {
+		return c.display
+	}
+	return c.text
+}
+
+// RichText returns the parts of a string cell shown in their own fonts, nil if the string has one font.
+// The text before the first run has the font of the cell.
+func (c Cell) RichText() []TextRun {
+	return c.runs
+}
+
+// Phonetic returns the phonetic reading of a string cell, like the furigana of Japanese text
+func (c Cell) Phonetic() string {
+	return c.phonetic
+}
+
+// Formula returns the formula of a formula cell as Excel shows it, like =SUM(A1:B3),
+// it is empty for other cells and for formulas that can not be decoded
+func (c Cell) Formula() string {
+	return c.formula
+}
+
+// FormulaRange returns the cells of the shared or array formula the formula cell belongs to
+func (c Cell) FormulaRange() (CellRange, bool) {
+	if c.shared == nil {
+		return CellRange{}, false
+	}
+	return c.shared.CellRange, true
+}
+
+// IsArrayFormula reports whether the cell belongs to an array formula, whose formula is shown like {=A1:A3*2}
+func (c Cell) IsArrayFormula() bool {
+	return c.shared != nil && c.shared.array
+}
+
+// FormatColor returns the color the number format shows the value in, like Red or Color10,
+// it is empty if the format has no color
+func (c Cell) FormatColor() string {
+	return c.color
+}
+
+// Float returns the value of a number or date cell
+func (c Cell) Float() (float64, error) {
+	if c.result != CellNumber && c.result != CellDate {
+		return 0, c.typeError(CellNumber)
+	}
+	return c.num, nil
+}
+
+// Int returns the value of a number or date cell truncated towards zero
+func (c Cell) Int() (int64, error) {
+	f, err := c.Float()
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Trunc(f)), nil
+}
+
+// Time returns the value of a number or date cell as time, according to the date mode of the workbook
+func (c Cell) Time() (time.Time, error) {
+	f, err := c.Float()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timeFromExcelTime(f, c.date1904), nil
+}
+
+// Bool returns the value of a boolean cell
+func (c Cell) Bool() (bool, error) {
+	if c.result != CellBool {
+		return false, c.typeError(CellBool)
+	}
+	return c.num != 0, nil
+}
+
+// ErrorCode returns the value of an error cell
+func (c Cell) ErrorCode() (ErrorCode, error) {
+	if c.result != CellError {
+		return 0, c.typeError(CellError)
+	}
+	return ErrorCode(c.num), nil
+}
+
+func (c Cell) typeError(want CellKind) error {
+	return fmt.Errorf("%w: %s cell is not %s", ErrCellType, c.result, want)
+}
+
+func stringCell(wb *WorkBook, xf uint16, str string) Cell {
+	text, color := wb.formatText(xf, str)
+	return Cell{kind: CellString, result: CellString, xf: xf, str: str, text: text, color: color}
+}
+
+func numberCell(wb *WorkBook, xf uint16, num float64) Cell {
+	kind := CellNumber
+	if wb.isDateXf(xf) {
+		kind = CellDate
+	}
+	display, color := wb.formatNumber(xf, num)
+	cell := Cell{kind: kind, result: kind, xf: xf, num: num, text: wb.numberString(xf, num), color: color, date1904: wb.dateMode == 1}
+	if display != cell.text {
+		cell.display = display
+	}
+	return cell
+}
+
+func blankCell(xf uint16) Cell {
+	return Cell{kind: CellBlank, result: CellBlank, xf: xf}
+}
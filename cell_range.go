@@ -52,7 +52,7 @@ type HyperLink struct {
 
 //get the hyperlink string, use the public variable Url to get the original Url
 func (h *HyperLink) String(wb *WorkBook) []string {
-	res := make([]string, h.LastColB-h.FristColB+1)
+	res := make([]string, int(h.LastColB)-int(h.FristColB)+1)
 	var str string
 	if h.IsURL {
 		str = fmt.Sprintf("%s(%s)", h.Description, h.URL)
@@ -60,8 +60,17 @@ func (h *HyperLink) String(wb *WorkBook) []string {
 		str = h.ExtendedFilePath
 	}
 
-	for i := uint16(0); i < h.LastColB-h.FristColB+1; i++ {
+	for i := range res {
 		res[i] = str
 	}
 	return res
 }
+
+func (h *HyperLink) cells(wb *WorkBook) []Cell {
+	strs := h.String(wb)
+	res := make([]Cell, len(strs))
+	for i, str := range strs {
+		res[i] = Cell{kind: CellString, result: CellString, str: str, text: str}
+	}
+	return res
+}
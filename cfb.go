@@ -0,0 +1,169 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// the special sector ids of the allocation tables
+const (
+	cfbDIFATSect  = 0xFFFFFFFC
+	cfbFATSect    = 0xFFFFFFFD
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbNoStream   = 0xFFFFFFFF
+)
+
+// the object types of directory entries
+const (
+	cfbStream = 2
+	cfbRoot   = 5
+)
+
+type cfbHeader struct {
+	Signature       uint64
+	Clsid           [16]byte
+	MinorVersion    uint16
+	MajorVersion    uint16
+	ByteOrder       uint16
+	SectorShift     uint16
+	MiniSectorShift uint16
+	_               [6]byte
+	NumDirSectors   uint32
+	NumFATSectors   uint32
+	DirStart        uint32
+	_               uint32
+	MiniCutoff      uint32
+	MiniFATStart    uint32
+	NumMiniFAT      uint32
+	DIFATStart      uint32
+	NumDIFAT        uint32
+	DIFAT           [109]uint32
+}
+
+type cfbDirEntry struct {
+	Name       [32]uint16
+	NameLength uint16
+	Type       byte
+	Color      byte
+	Left       uint32
+	Right      uint32
+	Child      uint32
+	Clsid      [16]byte
+	State      uint32
+	Created    uint64
+	Modified   uint64
+	Start      uint32
+	Size       uint64
+}
+
+// setName sets the name and its length, which counts the terminating zero in bytes
+func (e *cfbDirEntry) setName(name string) {
+	n := copy(e.Name[:len(e.Name)-1], utf16.Encode([]rune(name)))
+	e.NameLength = uint16(2 * (n + 1))
+}
+
+// writeCompoundFile writes an OLE2 compound file of 512 bytes sectors holding the stream as Workbook.
+// The stream is padded to the mini stream cutoff so it is stored in regular sectors, like Excel does.
+func writeCompoundFile(out io.Writer, stream []byte) error {
+	const sectorSize = 512
+	const miniCutoff = 4096
+	const idsPerSector = sectorSize / 4
+	if len(stream) < miniCutoff {
+		stream = append(stream, make([]byte, miniCutoff-len(stream))...)
+	}
+	if int64(len(stream)) > 0xFFFFFFFF {
+		return fmt.Errorf("xls: workbook stream of %d bytes too large", len(stream))
+	}
+	streamSectors := (len(stream) + sectorSize - 1) / sectorSize
+	// the sectors of the FAT and the DIFAT, which must cover themselves too
+	fatSectors, difatSectors := 0, 0
+	for {
+		total := streamSectors + 1 + fatSectors + difatSectors
+		if fatSectors*idsPerSector >= total {
+			break
+		}
+		fatSectors++
+		if fatSectors > len(cfbHeader{}.DIFAT) {
+			difatSectors = (fatSectors - len(cfbHeader{}.DIFAT) + idsPerSector - 2) / (idsPerSector - 1)
+		}
+	}
+	dirStart := uint32(streamSectors)
+	fatStart := dirStart + 1
+	difatStart := fatStart + uint32(fatSectors)
+
+	fat := make([]uint32, fatSectors*idsPerSector)
+	for i := range fat {
+		fat[i] = cfbFreeSect
+	}
+	for i := 0; i < streamSectors-1; i++ {
+		fat[i] = uint32(i + 1)
+	}
+	fat[streamSectors-1] = cfbEndOfChain
+	fat[dirStart] = cfbEndOfChain
+	for i := 0; i < fatSectors; i++ {
+		fat[fatStart+uint32(i)] = cfbFATSect
+	}
+	for i := 0; i < difatSectors; i++ {
+		fat[difatStart+uint32(i)] = cfbDIFATSect
+	}
+
+	h := cfbHeader{
+		Signature:       0xE11AB1A1E011CFD0,
+		MinorVersion:    0x3E,
+		MajorVersion:    3,
+		ByteOrder:       0xFFFE,
+		SectorShift:     9,
+		MiniSectorShift: 6,
+		NumFATSectors:   uint32(fatSectors),
+		DirStart:        dirStart,
+		MiniCutoff:      miniCutoff,
+		MiniFATStart:    cfbEndOfChain,
+		DIFATStart:      cfbEndOfChain,
+		NumDIFAT:        uint32(difatSectors),
+	}
+	var difat []uint32
+	for i := range h.DIFAT {
+		h.DIFAT[i] = cfbFreeSect
+	}
+	for i := 0; i < fatSectors; i++ {
+		if i < len(h.DIFAT) {
+			h.DIFAT[i] = fatStart + uint32(i)
+		} else {
+			difat = append(difat, fatStart+uint32(i))
+		}
+	}
+	if difatSectors > 0 {
+		h.DIFATStart = difatStart
+	}
+
+	root := cfbDirEntry{Type: cfbRoot, Color: 1, Left: cfbNoStream, Right: cfbNoStream, Child: 1, Start: cfbEndOfChain}
+	root.setName("Root Entry")
+	book := cfbDirEntry{Type: cfbStream, Color: 1, Left: cfbNoStream, Right: cfbNoStream, Child: cfbNoStream, Size: uint64(len(stream))}
+	book.setName("Workbook")
+	unused := cfbDirEntry{Left: cfbNoStream, Right: cfbNoStream, Child: cfbNoStream}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.Write(stream)
+	buf.Write(make([]byte, streamSectors*sectorSize-len(stream)))
+	binary.Write(&buf, binary.LittleEndian, []cfbDirEntry{root, book, unused, unused})
+	binary.Write(&buf, binary.LittleEndian, fat)
+	for i := 0; i < difatSectors; i++ {
+		ids := make([]uint32, idsPerSector)
+		for j := range ids {
+			ids[j] = cfbFreeSect
+		}
+		copy(ids[:idsPerSector-1], difat[i*(idsPerSector-1):])
+		ids[idsPerSector-1] = cfbEndOfChain
+		if i < difatSectors-1 {
+			ids[idsPerSector-1] = difatStart + uint32(i+1)
+		}
+		binary.Write(&buf, binary.LittleEndian, ids)
+	}
+	_, err := out.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,103 @@
+package xls
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// the encodings of the codepages of the CODEPAGE record, used for the byte strings of BIFF5
+var codepages = map[uint16]encoding.Encoding{
+	367:   charmap.Windows1252, // ASCII
+	437:   charmap.CodePage437,
+	850:   charmap.CodePage850,
+	852:   charmap.CodePage852,
+	855:   charmap.CodePage855,
+	858:   charmap.CodePage858,
+	860:   charmap.CodePage860,
+	862:   charmap.CodePage862,
+	863:   charmap.CodePage863,
+	865:   charmap.CodePage865,
+	866:   charmap.CodePage866,
+	874:   charmap.Windows874,
+	932:   japanese.ShiftJIS,
+	936:   simplifiedchinese.GBK,
+	949:   korean.EUCKR,
+	950:   traditionalchinese.Big5,
+	1200:  unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	10000: charmap.Macintosh,
+	10007: charmap.MacintoshCyrillic,
+	20866: charmap.KOI8R,
+	21866: charmap.KOI8U,
+	32768: charmap.Macintosh,   // Apple Roman
+	32769: charmap.Windows1252, // ANSI Latin I, BIFF2-BIFF3
+	65001: unicode.UTF8,
+}
+
+// defaultCodepage is used when the file has no or an unknown CODEPAGE record
+const defaultCodepage = 1252
+
+// codepageEncoding returns the encoding of the codepage, Windows-1252 if it is unknown
+func codepageEncoding(codepage uint16) encoding.Encoding {
+	if enc, ok := codepages[codepage]; ok {
+		return enc
+	}
+	return codepages[defaultCodepage]
+}
+
+// decodeCodepage decodes the bytes of a string in the given codepage
+func decodeCodepage(enc []byte, codepage uint16) string {
+	return decodeBytes(enc, codepageEncoding(codepage))
+}
+
+func decodeBytes(enc []byte, e encoding.Encoding) string {
+	out, err := e.NewDecoder().Bytes(enc)
+	if err != nil {
+		return string(enc)
+	}
+	return string(out)
+}
+
+// charsetEncoding resolves the charset given to Open, which overrides the CODEPAGE record of the file.
+// The charset is a name like windows-1252 or shift_jis, or a codepage number like cp932.
+// It is nil for an empty charset, utf-8 and unknown charsets, which keep the codepage of the file.
+func charsetEncoding(charset string) encoding.Encoding {
+	name := strings.ToLower(strings.TrimSpace(charset))
+	switch name {
+	case "", "utf-8", "utf8":
+		return nil
+	}
+	if no, err := strconv.ParseUint(strings.TrimPrefix(name, "cp"), 10, 16); err == nil {
+		return codepages[uint16(no)]
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+// decode decodes a BIFF5 byte string, with the charset given to Open or the codepage of the file
+func (w *WorkBook) decode(bts []byte) string {
+	if w.charset != nil {
+		return decodeBytes(bts, w.charset)
+	}
+	return decodeCodepage(bts, w.Codepage)
+}
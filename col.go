@@ -1,18 +1,16 @@
 package xls
 
 import (
+	"encoding/binary"
+	"fmt"
 	"math"
 	"strconv"
-	"strings"
-
-	"time"
-
-	"github.com/extrame/goyymmdd"
 )
 
 //content type
 type contentHandler interface {
 	String(*WorkBook) []string
+	cells(*WorkBook) []Cell
 	FirstCol() uint16
 	LastCol() uint16
 }
@@ -47,6 +45,10 @@ func (c *Col) String(wb *WorkBook) []string {
 	return []string{"default"}
 }
 
+func (c *Col) cells(wb *WorkBook) []Cell {
+	return []Cell{blankCell(0)}
+}
+
 // XfRk ...
 type XfRk struct {
 	Index uint16
@@ -54,34 +56,13 @@ type XfRk struct {
 }
 
 func (xf *XfRk) String(wb *WorkBook) string {
-	idx := int(xf.Index)
-	if len(wb.Xfs) > idx {
-		fNo := wb.Xfs[idx].formatNo()
-		if fNo >= 164 { // user defined format
-			if formatter := wb.Formats[fNo]; formatter != nil {
-				if strings.Contains(formatter.str, "#") || strings.Contains(formatter.str, ".00") {
-					//If format contains # or .00 then this is a number
-					return xf.Rk.String()
-				}
-				i, f, isFloat := xf.Rk.number()
-				if !isFloat {
-					f = float64(i)
-				}
-				t := timeFromExcelTime(f, wb.dateMode == 1)
-
-				return yymmdd.Format(t, formatter.str)
-			}
-			// see http://www.openoffice.org/sc/excelfileformat.pdf Page #174
-		} else if 14 <= fNo && fNo <= 17 || fNo == 22 || 27 <= fNo && fNo <= 36 || 50 <= fNo && fNo <= 58 { // jp. date format
-			i, f, isFloat := xf.Rk.number()
-			if !isFloat {
-				f = float64(i)
-			}
-			t := timeFromExcelTime(f, wb.dateMode == 1)
-			return t.Format(time.RFC3339) //TODO it should be international
-		}
-	}
-	return xf.Rk.String()
+	f, _ := xf.Rk.Float()
+	return wb.numberString(xf.Index, f)
+}
+
+func (xf *XfRk) cell(wb *WorkBook) Cell {
+	f, _ := xf.Rk.Float()
+	return numberCell(wb, xf.Index, f)
 }
 
 // RK ...
@@ -90,10 +71,11 @@ type RK uint32
 func (rk RK) number() (intNum int64, floatNum float64, isFloat bool) {
 	multiplied := rk & 1
 	isInt := rk & 2
-	val := rk >> 2
+	// the integer is signed, the float is the high 30 bits of a float64
+	val := int32(rk) >> 2
 	if isInt == 0 {
 		isFloat = true
-		floatNum = math.Float64frombits(uint64(val) << 34)
+		floatNum = math.Float64frombits(uint64(rk>>2) << 34)
 		if multiplied != 0 {
 			floatNum = floatNum / 100
 		}
@@ -147,6 +129,14 @@ func (c *MulrkCol) String(wb *WorkBook) []string {
 	return res
 }
 
+func (c *MulrkCol) cells(wb *WorkBook) []Cell {
+	var res = make([]Cell, len(c.Xfrks))
+	for i := 0; i < len(c.Xfrks); i++ {
+		res[i] = c.Xfrks[i].cell(wb)
+	}
+	return res
+}
+
 // MulBlankCol ...
 type MulBlankCol struct {
 	Col
@@ -163,6 +153,14 @@ func (c *MulBlankCol) String(wb *WorkBook) []string {
 	return make([]string, len(c.Xfs))
 }
 
+func (c *MulBlankCol) cells(wb *WorkBook) []Cell {
+	var res = make([]Cell, len(c.Xfs))
+	for i, xf := range c.Xfs {
+		res[i] = blankCell(xf)
+	}
+	return res
+}
+
 // NumberCol ...
 type NumberCol struct {
 	Col
@@ -171,7 +169,11 @@ type NumberCol struct {
 }
 
 func (c *NumberCol) String(wb *WorkBook) []string {
-	return []string{strconv.FormatFloat(c.Float, 'f', -1, 64)}
+	return []string{wb.numberString(c.Index, c.Float)}
+}
+
+func (c *NumberCol) cells(wb *WorkBook) []Cell {
+	return []Cell{numberCell(wb, c.Index, c.Float)}
 }
 
 // FormulaCol ...
@@ -184,10 +186,121 @@ type FormulaCol struct {
 		_       uint32
 	}
 	Bts []byte
+	// the result of a string formula, read from the following STRING record
+	str string
+	// the shared or array formula of the cell
+	shared *sharedFormula
+}
+
+// the kind of a cached formula result, stored in Result[0] when Result[6:8] is 0xFFFF
+const (
+	formulaResultString = 0
+	formulaResultBool   = 1
+	formulaResultError  = 2
+	formulaResultEmpty  = 3
+)
+
+// ErrorCode is the code of an Excel error value
+type ErrorCode byte
+
+// the error values of Excel
+const (
+	ErrorNull  ErrorCode = 0x00
+	ErrorDiv0  ErrorCode = 0x07
+	ErrorValue ErrorCode = 0x0F
+	ErrorRef   ErrorCode = 0x17
+	ErrorName  ErrorCode = 0x1D
+	ErrorNum   ErrorCode = 0x24
+	ErrorNA    ErrorCode = 0x2A
+)
+
+// the names Excel shows for error values
+var errorNames = map[ErrorCode]string{
+	ErrorNull:  "#NULL!",
+	ErrorDiv0:  "#DIV/0!",
+	ErrorValue: "#VALUE!",
+	ErrorRef:   "#REF!",
+	ErrorName:  "#NAME?",
+	ErrorNum:   "#NUM!",
+	ErrorNA:    "#N/A",
+}
+
+// String returns the name Excel shows for the error, like #N/A
+func (e ErrorCode) String() string {
+	if name, ok := errorNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("#ERR%d!", byte(e))
 }
 
+// Row returns the row index of the formula cell
+func (c *FormulaCol) Row() uint16 {
+	return c.Header.Row()
+}
+
+// FirstCol returns the column of the formula cell
+func (c *FormulaCol) FirstCol() uint16 {
+	return c.Header.FirstCol()
+}
+
+// LastCol returns the column of the formula cell
+func (c *FormulaCol) LastCol() uint16 {
+	return c.Header.LastCol()
+}
+
+// isNumber reports whether the cached result is a number instead of a typed value
+func (c *FormulaCol) isNumber() bool {
+	return c.Header.Result[6] != 0xff || c.Header.Result[7] != 0xff
+}
+
+func (c *FormulaCol) number() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(c.Header.Result[:]))
+}
+
+// String returns the result Excel calculated when the file was last saved
 func (c *FormulaCol) String(wb *WorkBook) []string {
-	return []string{"FormulaCol"}
+	if c.isNumber() {
+		return []string{wb.numberString(c.Header.IndexXf, c.number())}
+	}
+	switch c.Header.Result[0] {
+	case formulaResultString:
+		return []string{c.str}
+	case formulaResultBool:
+		if c.Header.Result[2] != 0 {
+			return []string{"TRUE"}
+		}
+		return []string{"FALSE"}
+	case formulaResultError:
+		return []string{ErrorCode(c.Header.Result[2]).String()}
+	}
+	return []string{""}
+}
+
+func (c *FormulaCol) cells(wb *WorkBook) []Cell {
+	var cell Cell
+	if c.isNumber() {
+		cell = numberCell(wb, c.Header.IndexXf, c.number())
+	} else {
+		text := c.String(wb)[0]
+		cell = Cell{xf: c.Header.IndexXf, text: text}
+		switch c.Header.Result[0] {
+		case formulaResultBool:
+			cell.result = CellBool
+			if c.Header.Result[2] != 0 {
+				cell.num = 1
+			}
+		case formulaResultError:
+			cell.result = CellError
+			cell.num = float64(c.Header.Result[2])
+		default:
+			cell.result = CellString
+			cell.str = text
+		}
+	}
+	cell.kind = CellFormula
+	cell.formula, _ = c.Formula(wb)
+	cell.shared = c.shared
+	return []Cell{cell}
 }
 
 // RkCol ...
@@ -200,6 +313,10 @@ func (c *RkCol) String(wb *WorkBook) []string {
 	return []string{c.Xfrk.String(wb)}
 }
 
+func (c *RkCol) cells(wb *WorkBook) []Cell {
+	return []Cell{c.Xfrk.cell(wb)}
+}
+
 // LabelsstCol ...
 type LabelsstCol struct {
 	Col
@@ -208,7 +325,25 @@ type LabelsstCol struct {
 }
 
 func (c *LabelsstCol) String(wb *WorkBook) []string {
-	return []string{wb.sst[int(c.Sst)]}
+	str, _ := wb.formatText(c.Xf, c.str(wb))
+	return []string{str}
+}
+
+func (c *LabelsstCol) cells(wb *WorkBook) []Cell {
+	cell := stringCell(wb, c.Xf, c.str(wb))
+	if rich := wb.sstRich[int(c.Sst)]; rich != nil {
+		cell.runs = rich.textRuns(wb, cell.str)
+		cell.phonetic = rich.phoneticText()
+	}
+	return []Cell{cell}
+}
+
+// str returns the shared string, empty if the index is out of the table
+func (c *LabelsstCol) str(wb *WorkBook) string {
+	if int64(c.Sst) < int64(len(wb.sst)) {
+		return wb.sst[c.Sst]
+	}
+	return ""
 }
 
 type labelCol struct {
@@ -217,7 +352,56 @@ type labelCol struct {
 }
 
 func (c *labelCol) String(wb *WorkBook) []string {
-	return []string{c.Str}
+	str, _ := wb.formatText(c.Xf, c.Str)
+	return []string{str}
+}
+
+func (c *labelCol) cells(wb *WorkBook) []Cell {
+	return []Cell{stringCell(wb, c.Xf, c.Str)}
+}
+
+// BoolErrCol is a cell holding a boolean or an error value
+type BoolErrCol struct {
+	Col
+	Xf    uint16
+	Value byte
+	Flag  byte
+}
+
+// IsError reports whether the cell holds an error instead of a boolean
+func (c *BoolErrCol) IsError() bool {
+	return c.Flag != 0
+}
+
+// Bool returns the boolean value of the cell
+func (c *BoolErrCol) Bool() bool {
+	return !c.IsError() && c.Value != 0
+}
+
+// ErrorCode returns the error value of the cell
+func (c *BoolErrCol) ErrorCode() ErrorCode {
+	return ErrorCode(c.Value)
+}
+
+func (c *BoolErrCol) String(wb *WorkBook) []string {
+	if c.IsError() {
+		return []string{c.ErrorCode().String()}
+	}
+	if c.Bool() {
+		return []string{"TRUE"}
+	}
+	return []string{"FALSE"}
+}
+
+func (c *BoolErrCol) cells(wb *WorkBook) []Cell {
+	cell := Cell{kind: CellBool, xf: c.Xf, num: float64(c.Value), text: c.String(wb)[0]}
+	if c.IsError() {
+		cell.kind = CellError
+	} else if c.Bool() {
+		cell.num = 1
+	}
+	cell.result = cell.kind
+	return []Cell{cell}
 }
 
 // BlankCol ...
@@ -229,3 +413,7 @@ type BlankCol struct {
 func (c *BlankCol) String(wb *WorkBook) []string {
 	return []string{""}
 }
+
+func (c *BlankCol) cells(wb *WorkBook) []Cell {
+	return []Cell{blankCell(c.Xf)}
+}
@@ -0,0 +1,84 @@
+package xls
+
+import "math"
+
+// colInfo is the content of a COLINFO record, the formatting of a range of columns
+type colInfo struct {
+	FirstCol uint16
+	LastCol  uint16
+	// the width in 1/256 of a character
+	Width uint16
+	Xf    uint16
+	Flags uint16
+}
+
+// the bits of the COLINFO flags
+const (
+	colHidden       = 0x0001
+	colOutlineShift = 8
+	colOutlineMask  = 0x07
+	colCollapsed    = 0x1000
+)
+
+// the pixels of the widest digit of the default font Arial 10, and the margins of a cell
+const (
+	digitPixels   = 7
+	columnPadding = 5
+)
+
+// defaultColumnChars is the width of the columns in characters when the sheet has no DEFCOLWIDTH record
+const defaultColumnChars = 8
+
+// defaultXf is the XF record of the cells formatted with the Normal style
+const defaultXf = 15
+
+// ColumnInfo is the formatting of a column
+type ColumnInfo struct {
+	// Width is the width in characters of the widest digit of the default font, with the margins of the cells
+	Width  float64
+	Hidden bool
+	// OutlineLevel is the level of the column in the outline, 0 if it is not grouped
+	OutlineLevel int
+	// Collapsed is set if the group of columns following the column is collapsed
+	Collapsed bool
+	// XF is the index of the XF record of the empty cells of the column
+	XF uint16
+}
+
+// Pixels returns the width of the column in pixels for the default font Arial 10
+func (c ColumnInfo) Pixels() int {
+	return int(math.Round(c.Width * digitPixels))
+}
+
+// Column returns the formatting of the Nth column, the default one if it has no COLINFO record
+func (w *WorkSheet) Column(i int) ColumnInfo {
+	for j := len(w.columns) - 1; j >= 0; j-- {
+		c := w.columns[j]
+		if i < int(c.FirstCol) || i > int(c.LastCol) {
+			continue
+		}
+		return ColumnInfo{
+			Width:        float64(c.Width) / 256,
+			Hidden:       c.Flags&colHidden != 0,
+			OutlineLevel: int(c.Flags >> colOutlineShift & colOutlineMask),
+			Collapsed:    c.Flags&colCollapsed != 0,
+			XF:           c.Xf,
+		}
+	}
+	return ColumnInfo{Width: w.DefaultColumnWidth(), XF: defaultXf}
+}
+
+// DefaultColumnWidth returns the width in characters of the columns without a COLINFO record,
+// from the STANDARDWIDTH record or else the DEFCOLWIDTH record
+func (w *WorkSheet) DefaultColumnWidth() float64 {
+	if w.standardWidth != 0 {
+		return float64(w.standardWidth) / 256
+	}
+	chars := w.defColWidth
+	if chars == 0 {
+		chars = defaultColumnChars
+	}
+	// Excel adds the margins to the width and rounds the pixels up to a multiple of 8
+	pixels := (int(chars)*digitPixels + columnPadding + 7) / 8 * 8
+	return float64(pixels) / digitPixels
+}
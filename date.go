@@ -1,6 +1,7 @@
 package xls
 
 import (
+	"fmt"
 	"math"
 	"time"
 )
@@ -29,14 +30,14 @@ func shiftJulianToNoon(julianDays, julianFraction float64) (float64, float64) {
 // Return the integer values for hour, minutes, seconds and
 // nanoseconds that comprised a given fraction of a day.
 func fractionOfADay(fraction float64) (hours, minutes, seconds, nanoseconds int) {
-	f := 5184000000000000 * fraction
-	nanoseconds = int(math.Mod(f, 1000000000))
+	const nanosPerDay = 24 * 60 * 60 * 1000000000
+	f := int64(math.Round(nanosPerDay * fraction))
+	nanoseconds = int(f % 1000000000)
 	f = f / 1000000000
-	seconds = int(math.Mod(f, 60))
-	f = f / 3600
-	minutes = int(math.Mod(f, 60))
+	seconds = int(f % 60)
 	f = f / 60
-	hours = int(f)
+	minutes = int(f % 60)
+	hours = int(f / 60)
 	return hours, minutes, seconds, nanoseconds
 }
 
@@ -100,3 +101,18 @@ func timeFromExcelTime(excelTime float64, date1904 bool) time.Time {
 	durationPart := time.Duration(dayNanoSeconds * floatPart)
 	return date.Add(durationDays).Add(durationPart)
 }
+
+// excelTime converts the wall clock of the time to the serial number of the 1900 date system.
+// Excel counts the 29th of February 1900 which did not exist, so earlier dates are one day less.
+func excelTime(t time.Time) (float64, error) {
+	if t.Year() < 1900 || t.Year() > 9999 {
+		return 0, fmt.Errorf("xls: %v out of the dates of excel", t)
+	}
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := float64(wall.Unix()-epoch.Unix())/86400 + float64(wall.Nanosecond())/(86400*1e9)
+	if days < 61 {
+		days--
+	}
+	return days, nil
+}
@@ -0,0 +1,30 @@
+package xls
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errRecordSize  = errors.New("xls: record too short")
+	errColumnRange = errors.New("xls: invalid cell range")
+	errFormula     = errors.New("xls: broken formula")
+)
+
+// ParseError is returned when a record of the workbook stream can not be read
+type ParseError struct {
+	// RecordID is the type of the record, 0 if its header could not be read
+	RecordID uint16
+	// Offset is the position of the record header in the workbook stream
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("xls: record 0x%X at offset %d: %v", e.RecordID, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
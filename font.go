@@ -1,5 +1,7 @@
 package xls
 
+import "fmt"
+
 // FontInfo contains font information
 type FontInfo struct {
 	Height     uint16
@@ -14,8 +16,142 @@ type FontInfo struct {
 	NameB      byte
 }
 
+// the bits of the font flags
+const (
+	fontItalic    = 0x0002
+	fontStrikeout = 0x0008
+	fontOutline   = 0x0010
+	fontShadow    = 0x0020
+)
+
+// the weight of bold text, normal text has 400
+const fontWeightBold = 700
+
+// fontAutoColor is the color index of the window text color
+const fontAutoColor = 0x7FFF
+
+// UnderlineKind is the kind of a font's underline
+type UnderlineKind byte
+
+// the underline kinds
+const (
+	UnderlineNone             UnderlineKind = 0x00
+	UnderlineSingle           UnderlineKind = 0x01
+	UnderlineDouble           UnderlineKind = 0x02
+	UnderlineSingleAccounting UnderlineKind = 0x21
+	UnderlineDoubleAccounting UnderlineKind = 0x22
+)
+
+var underlineNames = map[UnderlineKind]string{
+	UnderlineNone:             "none",
+	UnderlineSingle:           "single",
+	UnderlineDouble:           "double",
+	UnderlineSingleAccounting: "singleAccounting",
+	UnderlineDoubleAccounting: "doubleAccounting",
+}
+
+func (u UnderlineKind) String() string {
+	if name, ok := underlineNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("UnderlineKind(%d)", byte(u))
+}
+
+// Script is the vertical position of a font's text
+type Script uint16
+
+// the scripts
+const (
+	ScriptNormal Script = iota
+	ScriptSuper
+	ScriptSub
+)
+
+var scriptNames = [...]string{
+	ScriptNormal: "normal",
+	ScriptSuper:  "superscript",
+	ScriptSub:    "subscript",
+}
+
+func (s Script) String() string {
+	if int(s) < len(scriptNames) {
+		return scriptNames[s]
+	}
+	return fmt.Sprintf("Script(%d)", uint16(s))
+}
+
+// Size returns the height of the font in points
+func (f *FontInfo) Size() float64 {
+	return float64(f.Height) / 20
+}
+
+// Italic reports whether the text is italic
+func (f *FontInfo) Italic() bool {
+	return f.Flag&fontItalic != 0
+}
+
+// Strikeout reports whether the text is struck out
+func (f *FontInfo) Strikeout() bool {
+	return f.Flag&fontStrikeout != 0
+}
+
+// Outline reports whether only the outline of the text is shown, on Macs
+func (f *FontInfo) Outline() bool {
+	return f.Flag&fontOutline != 0
+}
+
+// Shadow reports whether the text has a shadow, on Macs
+func (f *FontInfo) Shadow() bool {
+	return f.Flag&fontShadow != 0
+}
+
+// Weight returns the boldness of the font from 100 to 1000, normal text has 400 and bold text 700
+func (f *FontInfo) Weight() int {
+	return int(f.Bold)
+}
+
+// IsBold reports whether the text is bold
+func (f *FontInfo) IsBold() bool {
+	return f.Bold >= fontWeightBold
+}
+
+// UnderlineKind returns the kind of the underline
+func (f *FontInfo) UnderlineKind() UnderlineKind {
+	return UnderlineKind(f.Underline)
+}
+
+// Script returns whether the text is superscript or subscript
+func (f *FontInfo) Script() Script {
+	return Script(f.Escapement)
+}
+
+// ColorIndex returns the index of the text color in the palette
+func (f *FontInfo) ColorIndex() uint16 {
+	return f.Color
+}
+
+// IsAutoColor reports whether the text has the window text color of the system
+func (f *FontInfo) IsAutoColor() bool {
+	return f.Color == fontAutoColor
+}
+
 // Font ...
 type Font struct {
 	Info *FontInfo
 	Name string
 }
+
+// Font returns the font of a FONT record index, nil if there is no such font.
+// The index 4 is left out as it did not exist in the first formats, so the index 5 is the fifth font.
+func (w *WorkBook) Font(index uint16) *Font {
+	if index == 4 {
+		return nil
+	}
+	if index > 4 {
+		index--
+	}
+	if int(index) < len(w.Fonts) {
+		return &w.Fonts[index]
+	}
+	return nil
+}
@@ -1,10 +1,1281 @@
 package xls
 
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
 // Format ...
 type Format struct {
 	Head struct {
 		Index uint16
 		Size  uint16
 	}
-	str string
+	str  string
+	code *numFormat
+}
+
+// String returns the format code, like #,##0.00
+func (f *Format) String() string {
+	return f.str
+}
+
+// the kinds of the tokens of a format code
+const (
+	tokLiteral = iota
+	tokDigit   // 0, # or ?
+	tokDecimal // .
+	tokComma   // , as thousands separator or scaling
+	tokPercent // %
+	tokExp     // E+, E-, e+ or e-
+	tokSlash   // / of a fraction
+	tokDenom   // fixed denominator of a fraction, like 8 in ?/8
+	tokText    // @
+	tokGeneral // General
+	tokDate    // y, m, d, h, s and the elapsed [h], [m], [s]
+	tokAmPm    // AM/PM, A/P
+	tokSubSec  // .0, .00 or .000 after seconds
+)
+
+// the parts of a number the digit placeholders belong to
+const (
+	partInt = iota
+	partFrac
+	partExp
+	partWhole
+	partNum
+	partDenom
+)
+
+type fmtToken struct {
+	kind int
+	text string
+	part int
+}
+
+// the section kinds
+const (
+	secNumber = iota
+	secGeneral
+	secDate
+	secText
+)
+
+type fmtCondition struct {
+	op    string
+	value float64
+}
+
+func (c *fmtCondition) match(v float64) bool {
+	switch c.op {
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	case "=":
+		return v == c.value
+	case "<>":
+		return v != c.value
+	}
+	return false
+}
+
+type fmtSection struct {
+	kind   int
+	color  string
+	cond   *fmtCondition
+	tokens []fmtToken
+
+	percent  int
+	scale    int
+	grouping bool
+	exp      bool
+	fraction bool
+	hour12   bool
+	subSec   int
+}
+
+// numFormat is a compiled number format code
+type numFormat struct {
+	sections []*fmtSection
+	text     *fmtSection
+}
+
+// the names of the colors of a format code
+var fmtColors = map[string]string{
+	"black":   "Black",
+	"blue":    "Blue",
+	"cyan":    "Cyan",
+	"green":   "Green",
+	"magenta": "Magenta",
+	"red":     "Red",
+	"white":   "White",
+	"yellow":  "Yellow",
+}
+
+// parseNumFormat compiles a number format code, any code is accepted,
+// unknown characters are shown as they are.
+func parseNumFormat(code string) *numFormat {
+	if code == "" {
+		code = "General"
+	}
+	f := new(numFormat)
+	for _, str := range splitSections(code) {
+		f.sections = append(f.sections, parseSection(str))
+	}
+	if len(f.sections) > 3 {
+		f.text = f.sections[3]
+		f.sections = f.sections[:3]
+	} else if last := f.sections[len(f.sections)-1]; last.kind == secText {
+		f.text = last
+		f.sections = f.sections[:len(f.sections)-1]
+	}
+	return f
+}
+
+// splitSections splits the code on the semicolons outside of quotes, escapes and brackets
+func splitSections(code string) []string {
+	var res []string
+	var quoted, bracket bool
+	start := 0
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case quoted:
+			quoted = c != '"'
+		case bracket:
+			bracket = c != ']'
+		case c == '"':
+			quoted = true
+		case c == '[':
+			bracket = true
+		case c == '\\' || c == '_' || c == '*':
+			if i+1 < len(code) {
+				_, n := utf8.DecodeRuneInString(code[i+1:])
+				i += n
+			}
+		case c == ';':
+			res = append(res, code[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, code[start:])
+}
+
+func parseSection(code string) *fmtSection {
+	s := new(fmtSection)
+	s.tokenize(code)
+	s.analyze()
+	return s
+}
+
+func (s *fmtSection) add(kind int, text string) {
+	s.tokens = append(s.tokens, fmtToken{kind: kind, text: text})
+}
+
+func (s *fmtSection) tokenize(code string) {
+	for i := 0; i < len(code); {
+		r, n := utf8.DecodeRuneInString(code[i:])
+		rest := code[i:]
+		lower := unicode.ToLower(r)
+		switch {
+		case r == '"':
+			end := strings.IndexByte(code[i+1:], '"')
+			if end < 0 {
+				end = len(code) - i - 1
+				n = len(code) - i
+			} else {
+				n = end + 2
+			}
+			s.add(tokLiteral, code[i+1:i+1+end])
+		case r == '\\' || r == '_' || r == '*':
+			if len(rest) > 1 {
+				next, m := utf8.DecodeRuneInString(rest[1:])
+				n += m
+				if r == '\\' {
+					s.add(tokLiteral, string(next))
+				} else if r == '_' {
+					s.add(tokLiteral, " ")
+				}
+			}
+		case r == '[':
+			if end := strings.IndexByte(rest, ']'); end < 0 {
+				s.add(tokLiteral, rest)
+				n = len(rest)
+			} else {
+				s.bracket(rest[1:end])
+				n = end + 1
+			}
+		case r == '0' || r == '#' || r == '?':
+			s.add(tokDigit, string(r))
+		case r == '.':
+			s.add(tokDecimal, ".")
+		case r == ',':
+			s.add(tokComma, ",")
+		case r == '%':
+			s.add(tokPercent, "%")
+		case r == '@':
+			s.add(tokText, "@")
+		case r == '/':
+			s.add(tokSlash, "/")
+			if m := countDigits(rest[1:]); m > 0 && rest[1] != '0' {
+				s.add(tokDenom, rest[1:1+m])
+				n += m
+			}
+		case (r == 'E' || r == 'e') && len(rest) > 1 && (rest[1] == '+' || rest[1] == '-'):
+			s.add(tokExp, rest[:2])
+			n = 2
+		case len(rest) >= 7 && strings.EqualFold(rest[:7], "general"):
+			s.add(tokGeneral, rest[:7])
+			n = 7
+		case len(rest) >= 5 && strings.EqualFold(rest[:5], "am/pm"):
+			s.add(tokAmPm, rest[:5])
+			n = 5
+		case len(rest) >= 3 && strings.EqualFold(rest[:3], "a/p"):
+			s.add(tokAmPm, rest[:3])
+			n = 3
+		case strings.HasPrefix(rest, "上午/下午"):
+			s.add(tokAmPm, "上午/下午")
+			n = len("上午/下午")
+		case lower == 'y' || lower == 'm' || lower == 'd' || lower == 'h' || lower == 's' || lower == 'e' || lower == 'g':
+			for n < len(rest) && unicode.ToLower(rune(rest[n])) == lower {
+				n++
+			}
+			s.add(tokDate, strings.ToLower(rest[:n]))
+		default:
+			s.add(tokLiteral, string(r))
+		}
+		i += n
+	}
+}
+
+func countDigits(str string) int {
+	n := 0
+	for n < len(str) && str[n] >= '0' && str[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// bracket handles the content of [] in a format code
+func (s *fmtSection) bracket(content string) {
+	lower := strings.ToLower(content)
+	switch {
+	case fmtColors[lower] != "":
+		s.color = fmtColors[lower]
+	case strings.HasPrefix(lower, "color"):
+		s.color = "Color" + content[5:]
+	case strings.HasPrefix(content, "<") || strings.HasPrefix(content, ">") || strings.HasPrefix(content, "="):
+		op := content[:1]
+		if len(content) > 1 && (content[1] == '=' || content[1] == '>') {
+			op = content[:2]
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(content[len(op):]), 64); err == nil {
+			s.cond = &fmtCondition{op: op, value: v}
+		}
+	case strings.HasPrefix(content, "$"):
+		// currency and locale, like [$€-407]
+		if i := strings.IndexByte(content, '-'); i >= 0 {
+			content = content[:i]
+		}
+		if len(content) > 1 {
+			s.add(tokLiteral, content[1:])
+		}
+	case lower != "" && (strings.Trim(lower, "h") == "" || strings.Trim(lower, "m") == "" || strings.Trim(lower, "s") == ""):
+		s.add(tokDate, "["+lower+"]")
+	}
+}
+
+// analyze finds out what kind of value the section formats and the role of its tokens
+func (s *fmtSection) analyze() {
+	for _, t := range s.tokens {
+		switch t.kind {
+		case tokDate, tokAmPm:
+			s.kind = secDate
+		case tokText:
+			if s.kind == secNumber {
+				s.kind = secText
+			}
+		case tokGeneral:
+			if s.kind == secNumber {
+				s.kind = secGeneral
+			}
+		}
+	}
+	switch s.kind {
+	case secDate:
+		s.analyzeDate()
+	case secNumber:
+		s.analyzeNumber()
+	}
+}
+
+func (s *fmtSection) analyzeDate() {
+	tokens := s.tokens
+	for i := range tokens {
+		t := &tokens[i]
+		switch t.kind {
+		case tokAmPm:
+			s.hour12 = true
+		case tokDecimal:
+			// fractions of a second follow the seconds
+			j := i + 1
+			for j < len(tokens) && tokens[j].kind == tokDigit && tokens[j].text == "0" {
+				j++
+			}
+			if j > i+1 && i > 0 && tokens[i-1].kind == tokDate && strings.HasPrefix(tokens[i-1].text, "s") {
+				t.kind = tokSubSec
+				t.text = strings.Repeat("0", j-i-1)
+				if len(t.text) > s.subSec {
+					s.subSec = len(t.text)
+				}
+				for k := i + 1; k < j; k++ {
+					tokens[k] = fmtToken{kind: tokLiteral}
+				}
+			} else {
+				t.kind = tokLiteral
+			}
+		case tokDate:
+			if t.text[0] == 'm' && len(t.text) <= 2 && s.isMinute(i) {
+				t.text = strings.Replace(t.text, "m", "n", -1)
+			}
+		case tokDigit, tokComma, tokPercent, tokSlash, tokDenom, tokExp, tokText, tokGeneral:
+			t.kind = tokLiteral
+		}
+	}
+}
+
+// isMinute reports whether the m token at i means minutes instead of a month,
+// m is minutes right after hours or right before seconds.
+func (s *fmtSection) isMinute(i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		if t := s.tokens[j]; t.kind == tokDate {
+			if t.text[0] == 'h' || strings.HasPrefix(t.text, "[h") {
+				return true
+			}
+			break
+		}
+	}
+	for j := i + 1; j < len(s.tokens); j++ {
+		if t := s.tokens[j]; t.kind == tokDate {
+			return t.text[0] == 's' || strings.HasPrefix(t.text, "[s")
+		}
+	}
+	return false
+}
+
+func (s *fmtSection) analyzeNumber() {
+	tokens := s.tokens
+	slash := -1
+	for i, t := range tokens {
+		if t.kind == tokSlash && i > 0 && tokens[i-1].kind == tokDigit &&
+			i+1 < len(tokens) && (tokens[i+1].kind == tokDigit || tokens[i+1].kind == tokDenom) {
+			slash = i
+			break
+		}
+	}
+	part := partInt
+	if slash >= 0 {
+		s.fraction = true
+		// the numerator is the group of digits right before the slash, a group before it is the whole number
+		i := slash - 1
+		for i >= 0 && tokens[i].kind == tokDigit {
+			tokens[i].part = partNum
+			i--
+		}
+		part = partNum
+		for ; i >= 0; i-- {
+			if tokens[i].kind == tokDigit {
+				tokens[i].part = partWhole
+				part = partWhole
+			}
+		}
+		for i := slash + 1; i < len(tokens); i++ {
+			if tokens[i].kind == tokDigit {
+				tokens[i].part = partDenom
+			}
+		}
+	}
+	for i := range tokens {
+		t := &tokens[i]
+		switch t.kind {
+		case tokDigit:
+			if slash < 0 {
+				t.part = part
+			}
+		case tokDecimal:
+			if slash < 0 && part == partInt {
+				part = partFrac
+			} else {
+				t.kind = tokLiteral
+			}
+		case tokExp:
+			if slash < 0 && !s.exp {
+				s.exp = true
+				part = partExp
+			} else {
+				t.kind = tokLiteral
+			}
+		case tokPercent:
+			s.percent++
+			t.kind = tokLiteral
+		case tokComma:
+			after := i+1 < len(tokens) && tokens[i+1].kind == tokDigit
+			before := i > 0 && (tokens[i-1].kind == tokDigit || tokens[i-1].kind == tokComma && tokens[i-1].text == "")
+			switch {
+			case after && before && part == partInt:
+				s.grouping = true
+				t.text = ""
+			case before && !after:
+				s.scale++
+				t.text = ""
+			default:
+				t.kind = tokLiteral
+			}
+		case tokSlash, tokDenom:
+			if slash < 0 {
+				t.kind = tokLiteral
+			}
+		case tokDate, tokAmPm, tokSubSec:
+			t.kind = tokLiteral
+		}
+	}
+}
+
+// section chooses the section to format the number with,
+// abs tells the sign is shown by the section instead of a minus
+func (f *numFormat) section(v float64) (s *fmtSection, abs bool) {
+	secs := f.sections
+	if len(secs) == 0 || len(secs) == 1 && secs[0].cond == nil {
+		if len(secs) == 0 {
+			return nil, false
+		}
+		return secs[0], false
+	}
+	if secs[0].cond == nil && secs[1].cond == nil {
+		switch {
+		case v < 0:
+			return secs[1], true
+		case v == 0 && len(secs) > 2:
+			return secs[2], false
+		}
+		return secs[0], false
+	}
+	cond0 := secs[0].cond
+	if cond0 == nil {
+		cond0 = &fmtCondition{op: ">", value: 0}
+		if len(secs) == 2 {
+			cond0.op = ">="
+		}
+	}
+	if cond0.match(v) {
+		return secs[0], false
+	}
+	if len(secs) == 1 {
+		return nil, false
+	}
+	cond1 := secs[1].cond
+	if cond1 == nil {
+		if len(secs) == 2 {
+			return secs[1], v < 0
+		}
+		cond1 = &fmtCondition{op: "<", value: 0}
+	}
+	if cond1.match(v) {
+		return secs[1], v < 0
+	}
+	if len(secs) > 2 {
+		return secs[2], v < 0
+	}
+	return nil, false
+}
+
+// formatNumber renders a number like Excel shows it, with the color of the section used
+func (f *numFormat) formatNumber(v float64, date1904 bool) (string, string) {
+	str, color, ok := f.format(v, date1904)
+	if !ok {
+		return formatGeneral(v), color
+	}
+	return str, color
+}
+
+// format renders a number with its section, false if the format can not render it,
+// like a number without a section or a negative date
+func (f *numFormat) format(v float64, date1904 bool) (str, color string, ok bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", "", false
+	}
+	s, abs := f.section(v)
+	if s == nil {
+		return "", "", false
+	}
+	switch s.kind {
+	case secDate:
+		if v < 0 {
+			return "", s.color, false
+		}
+		str = s.formatDate(v, date1904)
+	case secGeneral, secText:
+		str = s.render(math.Abs(v), nil)
+	default:
+		str = s.formatNumber(math.Abs(v))
+	}
+	if v < 0 && !abs && s.kind != secDate && strings.ContainsAny(str, "123456789") {
+		str = "-" + str
+	}
+	return str, s.color, true
+}
+
+// isGeneral reports whether the format is only General
+func (f *numFormat) isGeneral() bool {
+	if len(f.sections) != 1 || f.text != nil {
+		return false
+	}
+	s := f.sections[0]
+	return s.kind == secGeneral && s.color == "" && len(s.tokens) == 1 && s.tokens[0].kind == tokGeneral
+}
+
+// formatText renders a string with the text section, strings are shown as they are without it
+func (f *numFormat) formatText(str string) (string, string) {
+	if f.text == nil {
+		return str, ""
+	}
+	var b strings.Builder
+	for _, t := range f.text.tokens {
+		switch t.kind {
+		case tokText:
+			b.WriteString(str)
+		case tokLiteral:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String(), f.text.color
+}
+
+// isDate reports whether numbers are shown as dates or times
+func (f *numFormat) isDate() bool {
+	return len(f.sections) > 0 && f.sections[0].kind == secDate
+}
+
+// formatGeneral renders a number like the General format of Excel, which shows at most 11 characters
+// with up to 10 significant digits, and large or small numbers in scientific notation like 1E+20
+func formatGeneral(v float64) string {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	exp := int(math.Floor(math.Log10(math.Abs(v))))
+	width := 11
+	if v < 0 {
+		width++
+	}
+	switch {
+	case exp >= -4 && exp <= -1:
+		return trimFraction(strconv.FormatFloat(v, 'f', 9, 64))
+	case exp >= -9 && exp <= 9:
+		if str := trimFraction(strconv.FormatFloat(v, 'f', 12, 64)); len(str) <= width {
+			return str
+		}
+		if str := trimFraction(strconv.FormatFloat(v, 'f', 9-exp, 64)); len(str) <= width {
+			return str
+		}
+	case exp == 10:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	str := strconv.FormatFloat(v, 'E', 5, 64)
+	i := strings.IndexByte(str, 'E')
+	return trimFraction(str[:i]) + str[i:]
+}
+
+// trimFraction removes the trailing zeros of the decimals and a trailing decimal point
+func trimFraction(str string) string {
+	if !strings.Contains(str, ".") {
+		return str
+	}
+	return strings.TrimSuffix(strings.TrimRight(str, "0"), ".")
+}
+
+// render writes the tokens with the prepared digits of each part of the number
+func (s *fmtSection) render(general float64, digits map[int][]string) string {
+	var b strings.Builder
+	index := make(map[int]int)
+	for _, t := range s.tokens {
+		switch t.kind {
+		case tokLiteral, tokSlash, tokDenom:
+			b.WriteString(t.text)
+		case tokComma:
+			b.WriteString(t.text)
+		case tokGeneral:
+			b.WriteString(formatGeneral(general))
+		case tokDecimal:
+			b.WriteString(strings.Join(digits[-1], ""))
+			b.WriteString(".")
+		case tokExp:
+			b.WriteString(strings.Join(digits[-2], ""))
+		case tokDigit:
+			if ds := digits[t.part]; index[t.part] < len(ds) {
+				b.WriteString(ds[index[t.part]])
+			}
+			index[t.part]++
+		}
+	}
+	return b.String()
+}
+
+// placeholders returns the digit placeholders of the part
+func (s *fmtSection) placeholders(part int) []byte {
+	var res []byte
+	for _, t := range s.tokens {
+		if t.kind == tokDigit && t.part == part {
+			res = append(res, t.text[0])
+		}
+	}
+	return res
+}
+
+// pad is the output of a placeholder without a digit
+func pad(ph byte) string {
+	switch ph {
+	case '0':
+		return "0"
+	case '?':
+		return " "
+	}
+	return ""
+}
+
+// fillRight puts the digits into the placeholders aligned to the right, extra digits go to the first one
+func fillRight(digits string, ph []byte, grouping bool) []string {
+	res := make([]string, len(ph))
+	n := len(ph)
+	if n == 0 {
+		return res
+	}
+	extra := len(digits) - n
+	// position of the next output digit counted from the right
+	pos := len(digits)
+	if extra < 0 {
+		pos = n
+	}
+	group := func(b *strings.Builder, str string) {
+		pos--
+		b.WriteString(str)
+		if grouping && pos > 0 && pos%3 == 0 && str != "" && str != " " {
+			b.WriteString(",")
+		}
+	}
+	for k := 0; k < n; k++ {
+		var b strings.Builder
+		if k == 0 && extra > 0 {
+			for _, d := range digits[:extra] {
+				group(&b, string(d))
+			}
+		}
+		if i := k + extra; i >= 0 {
+			group(&b, digits[i:i+1])
+		} else {
+			group(&b, pad(ph[k]))
+		}
+		res[k] = b.String()
+	}
+	return res
+}
+
+// fillLeft puts the digits into the placeholders aligned to the left,
+// trailing zeros are dropped for # and shown as spaces for ?
+func fillLeft(digits string, ph []byte) []string {
+	res := make([]string, len(ph))
+	last := len(digits)
+	for last > 0 && digits[last-1] == '0' && last <= len(ph) && ph[last-1] != '0' {
+		last--
+	}
+	for k := range ph {
+		if k < last {
+			res[k] = digits[k : k+1]
+		} else {
+			res[k] = pad(ph[k])
+		}
+	}
+	return res
+}
+
+func (s *fmtSection) formatNumber(v float64) string {
+	for i := 0; i < s.percent; i++ {
+		v *= 100
+	}
+	for i := 0; i < s.scale; i++ {
+		v /= 1000
+	}
+	if math.IsInf(v, 0) {
+		return formatGeneral(v)
+	}
+	switch {
+	case s.fraction:
+		return s.formatFraction(v)
+	case s.exp:
+		return s.formatExp(v)
+	}
+	intPh, fracPh := s.placeholders(partInt), s.placeholders(partFrac)
+	intStr, fracStr := roundFixed(v, len(fracPh))
+	digits := map[int][]string{
+		partInt:  fillRight(intStr, intPh, s.grouping),
+		partFrac: fillLeft(fracStr, fracPh),
+	}
+	if len(intPh) == 0 && intStr != "" {
+		digits[-1] = []string{intStr}
+	}
+	return s.render(v, digits)
+}
+
+func (s *fmtSection) formatExp(v float64) string {
+	intPh, fracPh, expPh := s.placeholders(partInt), s.placeholders(partFrac), s.placeholders(partExp)
+	exp := 0
+	if v != 0 {
+		exp = int(math.Floor(math.Log10(v)))
+		n := len(intPh)
+		switch {
+		case n == 0:
+			exp++
+		case n > 1 && intPh[0] == '#':
+			// engineering notation, the exponent is a multiple of the integer digits
+			exp = int(math.Floor(float64(exp)/float64(n))) * n
+		default:
+			exp -= n - 1
+		}
+	}
+	intStr, fracStr := roundFixed(v/math.Pow(10, float64(exp)), len(fracPh))
+	if n := len(intPh); v != 0 && (n == 0 && intStr != "" || n > 0 && len(intStr) > n) {
+		// the mantissa was rounded up to the next power of ten
+		step := 1
+		if n > 1 && intPh[0] == '#' {
+			step = n
+		}
+		exp += step
+		intStr, fracStr = roundFixed(v/math.Pow(10, float64(exp)), len(fracPh))
+	}
+	sign := ""
+	var expTok string
+	for _, t := range s.tokens {
+		if t.kind == tokExp {
+			expTok = t.text
+		}
+	}
+	if exp < 0 {
+		sign = "-"
+		exp = -exp
+	} else if expTok[1] == '+' {
+		sign = "+"
+	}
+	digits := map[int][]string{
+		partInt:  fillRight(intStr, intPh, s.grouping),
+		partFrac: fillLeft(fracStr, fracPh),
+		partExp:  fillRight(strconv.Itoa(exp), expPh, false),
+		-2:       {expTok[:1] + sign},
+	}
+	if len(intPh) == 0 && intStr != "" {
+		digits[-1] = []string{intStr}
+	}
+	return s.render(v, digits)
+}
+
+// the largest denominator searched for fractions
+const maxDenominator = 9999
+
+func (s *fmtSection) formatFraction(v float64) string {
+	wholePh, numPh, denPh := s.placeholders(partWhole), s.placeholders(partNum), s.placeholders(partDenom)
+	mixed := len(wholePh) > 0
+	whole, frac := 0.0, v
+	if mixed {
+		whole, frac = math.Modf(v)
+	}
+	var num, den float64
+	var fixed string
+	for _, t := range s.tokens {
+		if t.kind == tokDenom {
+			fixed = t.text
+		}
+	}
+	if d, err := strconv.Atoi(fixed); err == nil && d > 0 {
+		den = float64(d)
+		num = math.Round(frac * den)
+	} else {
+		maxDen := int(math.Pow(10, float64(len(denPh)))) - 1
+		if maxDen > maxDenominator {
+			maxDen = maxDenominator
+		}
+		best := math.Inf(1)
+		for d := 1; d <= maxDen; d++ {
+			n := math.Round(frac * float64(d))
+			if diff := math.Abs(frac - n/float64(d)); diff < best {
+				best, num, den = diff, n, float64(d)
+			}
+		}
+	}
+	if mixed && num == den {
+		whole++
+		num = 0
+	}
+	digits := make(map[int][]string)
+	if mixed && num == 0 {
+		// only the whole number is shown, the fraction is blank
+		wholeStr := strconv.FormatFloat(whole, 'f', 0, 64)
+		if whole == 0 {
+			wholeStr = "0"
+		}
+		digits[partWhole] = fillRight(strings.TrimLeft(wholeStr, "0"), wholePh, s.grouping)
+		if whole == 0 {
+			digits[partWhole] = fillRight("0", wholePh, false)
+		}
+		return s.renderBlankFraction(digits)
+	}
+	if num == 0 {
+		den = 1
+	}
+	if mixed {
+		digits[partWhole] = fillRight(strings.TrimLeft(strconv.FormatFloat(whole, 'f', 0, 64), "0"), wholePh, s.grouping)
+	}
+	digits[partNum] = fillRight(strconv.FormatFloat(num, 'f', 0, 64), numPh, false)
+	denStr := strconv.FormatFloat(den, 'f', 0, 64)
+	// the denominator is aligned to the left
+	denDigits := make([]string, len(denPh))
+	extra := len(denStr) - len(denPh)
+	for k := range denPh {
+		switch {
+		case k == 0 && extra > 0:
+			denDigits[k] = denStr[:extra+1]
+		case extra > 0:
+			denDigits[k] = denStr[k+extra : k+extra+1]
+		case k < len(denStr):
+			denDigits[k] = denStr[k : k+1]
+		default:
+			denDigits[k] = pad(denPh[k])
+		}
+	}
+	digits[partDenom] = denDigits
+	return s.render(v, digits)
+}
+
+// renderBlankFraction renders a mixed fraction without fraction part,
+// everything between the whole number and the end of the denominator is shown as spaces
+func (s *fmtSection) renderBlankFraction(digits map[int][]string) string {
+	lastWhole, lastDenom := -1, -1
+	for i, t := range s.tokens {
+		switch {
+		case t.kind == tokDigit && t.part == partWhole:
+			lastWhole = i
+		case t.kind == tokDenom || t.kind == tokDigit && t.part == partDenom:
+			lastDenom = i
+		}
+	}
+	var b strings.Builder
+	index := 0
+	for i, t := range s.tokens {
+		switch {
+		case i > lastWhole && i <= lastDenom:
+			width := utf8.RuneCountInString(t.text)
+			if t.kind == tokDigit {
+				width = 1
+			}
+			b.WriteString(strings.Repeat(" ", width))
+		case t.kind == tokDigit && t.part == partWhole:
+			if index < len(digits[partWhole]) {
+				b.WriteString(digits[partWhole][index])
+			}
+			index++
+		case t.kind != tokDigit:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// roundFixed formats the positive number with n decimals, it rounds half away from zero
+// on the 15 significant digits Excel keeps. The integer part has no leading zeros.
+func roundFixed(v float64, n int) (intStr string, fracStr string) {
+	str := strconv.FormatFloat(v, 'e', 14, 64)
+	e := strings.IndexByte(str, 'e')
+	exp, _ := strconv.Atoi(str[e+1:])
+	digits := []byte(str[:1] + str[2:e])
+	point := exp + 1
+	if keep := point + n; keep < 0 {
+		digits = nil
+	} else if keep < len(digits) {
+		up := digits[keep] >= '5'
+		digits = digits[:keep]
+		if up {
+			i := keep - 1
+			for i >= 0 && digits[i] == '9' {
+				digits[i] = '0'
+				i--
+			}
+			if i < 0 {
+				digits = append([]byte{'1'}, digits...)
+				point++
+			} else {
+				digits[i]++
+			}
+		}
+	}
+	if point > 0 {
+		for len(digits) < point+n {
+			digits = append(digits, '0')
+		}
+		intStr, fracStr = string(digits[:point]), string(digits[point:point+n])
+	} else {
+		fracStr = strings.Repeat("0", -point) + string(digits)
+		for len(fracStr) < n {
+			fracStr += "0"
+		}
+		fracStr = fracStr[:n]
+	}
+	return strings.TrimLeft(intStr, "0"), fracStr
+}
+
+var (
+	monthNames   = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+)
+
+func (s *fmtSection) formatDate(v float64, date1904 bool) string {
+	unit := math.Pow(10, float64(s.subSec))
+	t := timeFromExcelTime(v, date1904).Round(time.Duration(float64(time.Second) / unit))
+	// elapsed time, in seconds rounded like the displayed time
+	elapsed := math.Round(v*86400*unit) / unit
+	var b strings.Builder
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case tokLiteral:
+			b.WriteString(tok.text)
+		case tokSubSec:
+			frac := t.Nanosecond() / int(math.Pow(10, float64(9-len(tok.text))))
+			b.WriteString(".")
+			b.WriteString(padInt(frac, len(tok.text)))
+		case tokAmPm:
+			b.WriteString(ampm(tok.text, t.Hour() >= 12))
+		case tokDate:
+			b.WriteString(s.dateToken(tok.text, t, elapsed))
+		}
+	}
+	return b.String()
+}
+
+func (s *fmtSection) dateToken(tok string, t time.Time, elapsed float64) string {
+	switch tok {
+	case "y", "yy":
+		return padInt(t.Year()%100, 2)
+	case "m":
+		return strconv.Itoa(int(t.Month()))
+	case "mm":
+		return padInt(int(t.Month()), 2)
+	case "mmm":
+		return monthNames[t.Month()-1][:3]
+	case "mmmmm":
+		return monthNames[t.Month()-1][:1]
+	case "d":
+		return strconv.Itoa(t.Day())
+	case "dd":
+		return padInt(t.Day(), 2)
+	case "ddd":
+		return weekdayNames[t.Weekday()][:3]
+	case "h", "hh":
+		h := t.Hour()
+		if s.hour12 {
+			h = (h+11)%12 + 1
+		}
+		return padInt(h, len(tok))
+	case "n", "nn":
+		return padInt(t.Minute(), len(tok))
+	case "s", "ss":
+		return padInt(t.Second(), len(tok))
+	}
+	switch {
+	case tok[0] == 'g':
+		// the names of the eras are not supported
+		return ""
+	case tok[0] == 'y' || tok[0] == 'e':
+		return padInt(t.Year(), 4)
+	case tok[0] == 'm':
+		return monthNames[t.Month()-1]
+	case tok[0] == 'd':
+		return weekdayNames[t.Weekday()]
+	case tok[0] == '[':
+		var total float64
+		switch tok[1] {
+		case 'h':
+			total = elapsed / 3600
+		case 'm':
+			total = elapsed / 60
+		default:
+			total = elapsed
+		}
+		return padInt(int(math.Floor(total)), len(tok)-2)
+	}
+	return tok
+}
+
+func padInt(i int, width int) string {
+	str := strconv.Itoa(i)
+	for len(str) < width {
+		str = "0" + str
+	}
+	return str
+}
+
+func ampm(tok string, pm bool) string {
+	if tok == "上午/下午" {
+		if pm {
+			return "下午"
+		}
+		return "上午"
+	}
+	// the case of the token is kept, like am/pm shows am
+	i := strings.IndexByte(tok, '/')
+	if pm {
+		return tok[i+1:]
+	}
+	return tok[:i]
+}
+
+// builtInFormats are the formats Excel knows without FORMAT record
+var builtInFormats = map[uint16]string{
+	0:  "General",
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	5:  `"$"#,##0_);\("$"#,##0\)`,
+	6:  `"$"#,##0_);[Red]\("$"#,##0\)`,
+	7:  `"$"#,##0.00_);\("$"#,##0.00\)`,
+	8:  `"$"#,##0.00_);[Red]\("$"#,##0.00\)`,
+	9:  "0%",
+	10: "0.00%",
+	11: "0.00E+00",
+	12: "# ?/?",
+	13: "# ??/??",
+	14: "m/d/yy",
+	15: "d-mmm-yy",
+	16: "d-mmm",
+	17: "mmm-yy",
+	18: "h:mm AM/PM",
+	19: "h:mm:ss AM/PM",
+	20: "h:mm",
+	21: "h:mm:ss",
+	22: "m/d/yy h:mm",
+	37: "#,##0_);(#,##0)",
+	38: "#,##0_);[Red](#,##0)",
+	39: "#,##0.00_);(#,##0.00)",
+	40: "#,##0.00_);[Red](#,##0.00)",
+	41: `_(* #,##0_);_(* \(#,##0\);_(* "-"_);_(@_)`,
+	42: `_("$"* #,##0_);_("$"* \(#,##0\);_("$"* "-"_);_(@_)`,
+	43: `_(* #,##0.00_);_(* \(#,##0.00\);_(* "-"??_);_(@_)`,
+	44: `_("$"* #,##0.00_);_("$"* \(#,##0.00\);_("$"* "-"??_);_(@_)`,
+	45: "mm:ss",
+	46: "[h]:mm:ss",
+	47: "mm:ss.0",
+	48: "##0.0E+0",
+	49: "@",
+}
+
+// cjkFormats are the built-in date formats 27-36 and 50-58 of the CJK versions of Excel,
+// by the codepage of the locale. The era years of Japanese and Taiwanese dates are shown as Gregorian years.
+var cjkFormats = map[uint16]map[uint16]string{
+	932: { // japanese
+		27: `[$-411]ge.m.d`,
+		28: `[$-411]ggge"年"m"月"d"日"`,
+		29: `[$-411]ggge"年"m"月"d"日"`,
+		30: "m/d/yy",
+		31: `yyyy"年"m"月"d"日"`,
+		32: `h"時"mm"分"`,
+		33: `h"時"mm"分"ss"秒"`,
+		34: `yyyy"年"m"月"`,
+		35: `m"月"d"日"`,
+		36: `[$-411]ge.m.d`,
+		50: `[$-411]ge.m.d`,
+		51: `[$-411]ggge"年"m"月"d"日"`,
+		52: `yyyy"年"m"月"`,
+		53: `m"月"d"日"`,
+		54: `[$-411]ggge"年"m"月"d"日"`,
+		55: `yyyy"年"m"月"`,
+		56: `m"月"d"日"`,
+		57: `[$-411]ge.m.d`,
+		58: `[$-411]ggge"年"m"月"d"日"`,
+	},
+	936: { // simplified chinese
+		27: `yyyy"年"m"月"`,
+		28: `m"月"d"日"`,
+		29: `m"月"d"日"`,
+		30: "m-d-yy",
+		31: `yyyy"年"m"月"d"日"`,
+		32: `h"时"mm"分"`,
+		33: `h"时"mm"分"ss"秒"`,
+		34: `上午/下午h"时"mm"分"`,
+		35: `上午/下午h"时"mm"分"ss"秒"`,
+		36: `yyyy"年"m"月"`,
+		50: `yyyy"年"m"月"`,
+		51: `m"月"d"日"`,
+		52: `yyyy"年"m"月"`,
+		53: `m"月"d"日"`,
+		54: `m"月"d"日"`,
+		55: `上午/下午h"时"mm"分"`,
+		56: `上午/下午h"时"mm"分"ss"秒"`,
+		57: `yyyy"年"m"月"`,
+		58: `m"月"d"日"`,
+	},
+	949: { // korean
+		27: `yyyy"年" mm"月" dd"日"`,
+		28: "mm-dd",
+		29: "mm-dd",
+		30: "mm-dd-yy",
+		31: `yyyy"년" mm"월" dd"일"`,
+		32: `h"시" mm"분"`,
+		33: `h"시" mm"분" ss"초"`,
+		34: "yyyy-mm-dd",
+		35: "yyyy-mm-dd",
+		36: `yyyy"年" mm"月" dd"日"`,
+		50: `yyyy"年" mm"月" dd"日"`,
+		51: "mm-dd",
+		52: "yyyy-mm-dd",
+		53: "yyyy-mm-dd",
+		54: "mm-dd",
+		55: "yyyy-mm-dd",
+		56: "yyyy-mm-dd",
+		57: `yyyy"年" mm"月" dd"日"`,
+		58: "mm-dd",
+	},
+	950: { // traditional chinese
+		27: `[$-404]e/m/d`,
+		28: `[$-404]e"年"m"月"d"日"`,
+		29: `[$-404]e"年"m"月"d"日"`,
+		30: "m/d/yy",
+		31: `yyyy"年"m"月"d"日"`,
+		32: `hh"時"mm"分"`,
+		33: `hh"時"mm"分"ss"秒"`,
+		34: `上午/下午hh"時"mm"分"`,
+		35: `上午/下午hh"時"mm"分"ss"秒"`,
+		36: `[$-404]e/m/d`,
+		50: `[$-404]e/m/d`,
+		51: `[$-404]e"年"m"月"d"日"`,
+		52: `上午/下午hh"時"mm"分"`,
+		53: `上午/下午hh"時"mm"分"ss"秒"`,
+		54: `[$-404]e"年"m"月"d"日"`,
+		55: `上午/下午hh"時"mm"分"`,
+		56: `上午/下午hh"時"mm"分"ss"秒"`,
+		57: `[$-404]e/m/d`,
+		58: `[$-404]e"年"m"月"d"日"`,
+	},
+}
+
+// fontCharsets are the codepages of the font character sets of the CJK locales
+var fontCharsets = map[byte]uint16{
+	128: 932, // SHIFTJIS_CHARSET
+	129: 949, // HANGUL_CHARSET
+	134: 936, // GB2312_CHARSET
+	136: 950, // CHINESEBIG5_CHARSET
+}
+
+// cjkCodepage finds the locale of the CJK built-in formats. BIFF8 files always have the codepage 1200,
+// so it is taken from the character set of the first font made for a CJK locale. It is 0 if the workbook
+// tells no CJK locale, the CJK formats are General then.
+func (w *WorkBook) cjkCodepage() uint16 {
+	if _, ok := cjkFormats[w.Codepage]; ok {
+		return w.Codepage
+	}
+	for _, f := range w.Fonts {
+		if f.Info == nil {
+			continue
+		}
+		if cp, ok := fontCharsets[f.Info.Charset]; ok {
+			return cp
+		}
+	}
+	return 0
+}
+
+var (
+	builtInCodes = compileFormats(builtInFormats)
+	cjkCodes     = make(map[uint16]map[uint16]*numFormat)
+)
+
+func init() {
+	for cp, formats := range cjkFormats {
+		cjkCodes[cp] = compileFormats(formats)
+	}
+}
+
+func compileFormats(formats map[uint16]string) map[uint16]*numFormat {
+	res := make(map[uint16]*numFormat, len(formats))
+	for no, str := range formats {
+		res[no] = parseNumFormat(str)
+	}
+	return res
+}
+
+// format resolves the format number to its code, from the FORMAT records of the file first,
+// then from the built-in formats. Unknown formats are General.
+func (w *WorkBook) format(fNo uint16) (string, *numFormat) {
+	if formatter := w.Formats[fNo]; formatter != nil {
+		if formatter.code == nil {
+			return formatter.str, parseNumFormat(formatter.str)
+		}
+		return formatter.str, formatter.code
+	}
+	if str, ok := builtInFormats[fNo]; ok {
+		return str, builtInCodes[fNo]
+	}
+	cp := w.cjkCodepage()
+	if str, ok := cjkFormats[cp][fNo]; ok {
+		return str, cjkCodes[cp][fNo]
+	}
+	return builtInFormats[0], builtInCodes[0]
+}
+
+// FormatCode returns the number format code of the XF, like #,##0.00
+func (w *WorkBook) FormatCode(xf uint16) string {
+	str, _ := w.xfFormat(xf)
+	return str
+}
+
+func (w *WorkBook) xfFormat(xf uint16) (string, *numFormat) {
+	var fNo uint16
+	if idx := int(xf); idx < len(w.Xfs) {
+		fNo = w.Xfs[idx].formatNo()
+	}
+	return w.format(fNo)
+}
+
+// isDateXf reports whether numbers with the XF are shown as dates
+func (w *WorkBook) isDateXf(xf uint16) bool {
+	_, code := w.xfFormat(xf)
+	return code.isDate()
+}
+
+// formatNumber renders the number with the format of the XF and returns the color of the format
+func (w *WorkBook) formatNumber(xf uint16, v float64) (string, string) {
+	_, code := w.xfFormat(xf)
+	return code.formatNumber(v, w.dateMode == 1)
+}
+
+// numberString renders the number for Row.Col and String, like formatNumber but a number in General format
+// or one the format can not render keeps all its digits instead of the 11 characters Excel shows
+func (w *WorkBook) numberString(xf uint16, v float64) string {
+	_, code := w.xfFormat(xf)
+	if code.isGeneral() {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if str, _, ok := code.format(v, w.dateMode == 1); ok {
+		return str
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatText renders the string with the text section of the format of the XF
+func (w *WorkBook) formatText(xf uint16, str string) (string, string) {
+	_, code := w.xfFormat(xf)
+	return code.formatText(str)
 }
@@ -0,0 +1,147 @@
+package xls
+
+import "testing"
+
+func TestFormatNumber(t *testing.T) {
+	cases := []struct {
+		code  string
+		value float64
+		want  string
+		color string
+	}{
+		{"General", 1234.5, "1234.5", ""},
+		{"General", 0.1 + 0.2, "0.3", ""},
+		{"General", 1e20, "1E+20", ""},
+		{"General", -1.5e-12, "-1.5E-12", ""},
+		{"General", 1.0 / 3, "0.333333333", ""},
+		{"General", 123456.789012345, "123456.789", ""},
+		{"General", 12345678901, "12345678901", ""},
+		{"General", 123456789012, "1.23457E+11", ""},
+		{"General", 0.0000123, "0.0000123", ""},
+		{"General", -42, "-42", ""},
+		{"0", 2.5, "3", ""},
+		{"0.00", 1.005, "1.01", ""},
+		{"0.00", -0.001, "0.00", ""},
+		{"#,##0", 1234567, "1,234,567", ""},
+		{"#,##0.00", -1234.5, "-1,234.50", ""},
+		{"#,##0,", 1234567, "1,235", ""},
+		{"0.0,,", 1234567, "1.2", ""},
+		{"0%", 0.256, "26%", ""},
+		{"0.00%", 0.25, "25.00%", ""},
+		{"0.00E+00", 12345, "1.23E+04", ""},
+		{"0.00E+00", 0.00012, "1.20E-04", ""},
+		{"##0.0E+0", 12345, "12.3E+3", ""},
+		{"# ?/?", 1.25, "1 1/4", ""},
+		{"# ??/??", 3.14159, "3 14/99", ""},
+		{"?/8", 0.5, "4/8", ""},
+		{"# ?/?", 2, "2    ", ""},
+		{"#.##", 0.5, ".5", ""},
+		{"0.##", 5, "5.", ""},
+		{"0.0?", 1.5, "1.5 ", ""},
+		{"000-00-0000", 123456789, "123-45-6789", ""},
+		{`"$"#,##0_);\("$"#,##0\)`, -1234, "($1,234)", ""},
+		{`"$"#,##0_);[Red]\("$"#,##0\)`, 1234, "$1,234 ", ""},
+		{`"$"#,##0_);[Red]\("$"#,##0\)`, -1234, "($1,234)", "Red"},
+		{`0.00;-0.00;"zero"`, 0, "zero", ""},
+		{`[>100][Blue]0;[<=100]0.0`, 150, "150", "Blue"},
+		{`[>100][Blue]0;[<=100]0.0`, 50, "50.0", ""},
+		{`[Color10]0`, 5, "5", "Color10"},
+		{`_-* #,##0.00_-;\-* #,##0.00_-;_-* "-"??_-;_-@_-`, 1234.5, " 1,234.50 ", ""},
+		{`_-* #,##0.00_-;\-* #,##0.00_-;_-* "-"??_-;_-@_-`, 0, " -   ", ""},
+		{`[$€-407] #,##0.00`, 12.5, "€ 12.50", ""},
+		{"yyyy-mm-dd", 45000, "2023-03-15", ""},
+		{"d-mmm-yy", 45000, "15-Mar-23", ""},
+		{"dddd, mmmm d", 45000, "Wednesday, March 15", ""},
+		{"h:mm AM/PM", 0.75, "6:00 PM", ""},
+		{"hh:mm:ss", 0.5 + 1.0/86400, "12:00:01", ""},
+		{"[h]:mm", 1.5, "36:00", ""},
+		{"mm:ss.0", 1.0 / 86400 * 61.25, "01:01.3", ""},
+		{"m/d/yy h:mm", 45000.25, "3/15/23 6:00", ""},
+	}
+	for _, c := range cases {
+		str, color := parseNumFormat(c.code).formatNumber(c.value, false)
+		if str != c.want || color != c.color {
+			t.Errorf("%s of %v is %q %q instead of %q %q", c.code, c.value, str, color, c.want, c.color)
+		}
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	f := parseNumFormat(`0;-0;0;"Name: "@`)
+	if str, _ := f.formatText("abc"); str != "Name: abc" {
+		t.Errorf("text is %q", str)
+	}
+	if str, _ := parseNumFormat("0.00").formatText("abc"); str != "abc" {
+		t.Errorf("text is %q", str)
+	}
+}
+
+func TestBuiltInFormats(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	for _, no := range []uint16{0, 2, 4, 10, 14, 44, 31, 163} {
+		wb.Xfs = append(wb.Xfs, &Xf8{Format: no})
+	}
+	cases := []struct {
+		xf    uint16
+		value float64
+		code  string
+		want  string
+	}{
+		{0, 1234.5, "General", "1234.5"},
+		{1, 3, "0.00", "3.00"},
+		{2, 1234.5, "#,##0.00", "1,234.50"},
+		{3, 0.125, "0.00%", "12.50%"},
+		{4, 45000, "m/d/yy", "3/15/23"},
+		{5, -12, `_("$"* #,##0.00_);_("$"* \(#,##0.00\);_("$"* "-"??_);_(@_)`, " $(12.00)"},
+		// a CJK format without a CJK font is General
+		{6, 45000, "General", "45000"},
+		{7, 7, "General", "7"},
+	}
+	for _, c := range cases {
+		if code := wb.FormatCode(c.xf); code != c.code {
+			t.Errorf("xf %d has format %q instead of %q", c.xf, code, c.code)
+		}
+		if str, _ := wb.formatNumber(c.xf, c.value); str != c.want {
+			t.Errorf("xf %d formats %v as %q instead of %q", c.xf, c.value, str, c.want)
+		}
+	}
+
+	// Row.Col shows a negative date, which has no rendering, and a General number with all their digits
+	for _, c := range []struct {
+		xf    uint16
+		value float64
+		want  string
+	}{
+		{4, -1.5, "-1.5"},
+		{0, 1.0 / 3, "0.3333333333333333"},
+		{1, 3, "3.00"},
+	} {
+		if str := (&NumberCol{Index: c.xf, Float: c.value}).String(wb)[0]; str != c.want {
+			t.Errorf("xf %d shows %v as %q instead of %q", c.xf, c.value, str, c.want)
+		}
+	}
+}
+
+func TestCJKFormats(t *testing.T) {
+	cases := []struct {
+		charset byte
+		format  uint16
+		want    string
+	}{
+		{134, 32, "13时30分"},
+		{128, 32, "13時30分"},
+		{129, 31, "2023년 03월 15일"},
+		{136, 34, "下午01時30分"},
+		// the Latin fonts tell no locale
+		{0, 31, "45000.5625"},
+		{238, 32, "45000.5625"},
+	}
+	for _, c := range cases {
+		// BIFF8 files have the codepage 1200, the locale is in the charset of the fonts
+		wb := &WorkBook{Codepage: 1200, Formats: make(map[uint16]*Format), Xfs: []stXfData{&Xf8{Format: c.format}}}
+		wb.Fonts = []Font{{Info: &FontInfo{}}, {Info: &FontInfo{Charset: c.charset}}}
+		if str, _ := wb.formatNumber(0, 45000.5625); str != c.want {
+			t.Errorf("charset %d formats %d as %q instead of %q", c.charset, c.format, str, c.want)
+		}
+	}
+}
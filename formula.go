@@ -0,0 +1,498 @@
+package xls
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// the names of the built-in NAME records, which store a single character code as name
+var builtinNames = [...]string{
+	"Consolidate_Area", "Auto_Open", "Auto_Close", "Extract", "Database", "Criteria", "Print_Area",
+	"Print_Titles", "Recorder", "Data_Form", "Auto_Activate", "Auto_Deactivate", "Sheet_Title", "_FilterDatabase",
+}
+
+// xti is a reference to sheets of a SUPBOOK, one entry of the EXTERNSHEET record
+type xti struct {
+	Book  uint16
+	First uint16
+	Last  uint16
+}
+
+// supBook is a workbook referenced by formulas, read from a SUPBOOK record and its EXTERNNAME records
+type supBook struct {
+	// self is set for the workbook itself, its sheets are the BOUNDSHEETs
+	self  bool
+	addin bool
+	// file is the name of an external workbook, with its sheets and names
+	file   string
+	sheets []string
+	names  []string
+	// broken is set for the placeholder of a SUPBOOK record that can not be read
+	broken bool
+}
+
+// sharedFormula is the formula of a SHRFMLA or ARRAY record, which the FORMULA records of its range refer to
+type sharedFormula struct {
+	CellRange
+	array bool
+	// the size and the tokens of the formula, followed by the extra data, like FormulaCol.Bts
+	tokens []byte
+}
+
+// the operators of the binary operator tokens
+var binaryOperators = map[byte]string{
+	0x03: "+", 0x04: "-", 0x05: "*", 0x06: "/", 0x07: "^", 0x08: "&",
+	0x09: "<", 0x0A: "<=", 0x0B: "=", 0x0C: ">=", 0x0D: ">", 0x0E: "<>",
+	0x0F: " ", 0x10: ",", 0x11: ":",
+}
+
+// Formula returns the formula of the cell as Excel shows it, like =SUM(A1:B3)*Sheet2!C4.
+// The cells of a shared formula get it with their relative references, the cells of an array formula
+// get it in braces like {=A1:A3*2}. Only BIFF8 formulas are decoded.
+func (c *FormulaCol) Formula(wb *WorkBook) (string, error) {
+	if wb.Is5ver {
+		return "", fmt.Errorf("%w: BIFF5 formulas are not supported", errFormula)
+	}
+	tokens := c.Bts
+	if c.shared != nil {
+		tokens = c.shared.tokens
+	}
+	if len(tokens) < 2 {
+		return "", errRecordSize
+	}
+	size := int(binary.LittleEndian.Uint16(tokens))
+	if 2+size > len(tokens) {
+		return "", errRecordSize
+	}
+	text, err := wb.decompile(tokens[2:2+size], tokens[2+size:], c.Header.RowB, c.Header.FirstColB)
+	if err != nil {
+		return "", err
+	}
+	if c.shared != nil && c.shared.array {
+		return "{=" + text + "}", nil
+	}
+	return "=" + text, nil
+}
+
+// SharedRange returns the cells of the shared or array formula the cell belongs to
+func (c *FormulaCol) SharedRange() (CellRange, bool) {
+	if c.shared == nil {
+		return CellRange{}, false
+	}
+	return c.shared.CellRange, true
+}
+
+// expCell returns the first cell of the shared or array formula a tExp token refers to
+func (c *FormulaCol) expCell() ([2]uint16, bool) {
+	if len(c.Bts) < 7 || c.Bts[2] != 0x01 {
+		return [2]uint16{}, false
+	}
+	return [2]uint16{binary.LittleEndian.Uint16(c.Bts[3:]), binary.LittleEndian.Uint16(c.Bts[5:])}, true
+}
+
+// decompile turns the tokens of a formula, in reverse polish notation, back to the text of the formula.
+// The extra data holds the constant arrays, relative references of shared formulas are based on the row and the column.
+func (w *WorkBook) decompile(rgce, extra []byte, row, col uint16) (string, error) {
+	r := &ptgReader{bts: rgce}
+	ex := &ptgReader{bts: extra}
+	var stack []string
+	pop := func(id byte, n int) ([]string, error) {
+		if n > len(stack) {
+			return nil, fmt.Errorf("%w: token 0x%02X misses operands", errFormula, id)
+		}
+		args := append([]string(nil), stack[len(stack)-n:]...)
+		stack = stack[:len(stack)-n]
+		return args, nil
+	}
+	for r.pos < len(r.bts) {
+		id := r.u8()
+		if op, ok := binaryOperators[id]; ok {
+			args, err := pop(id, 2)
+			if err != nil {
+				return "", err
+			}
+			stack = append(stack, args[0]+op+args[1])
+			continue
+		}
+		var token string
+		switch id {
+		case 0x01: // tExp
+			return "", fmt.Errorf("%w: missing shared formula", errFormula)
+		case 0x02: // tTbl
+			return "", fmt.Errorf("%w: data table", errFormula)
+		case 0x12, 0x13, 0x14, 0x15: // tUplus, tUminus, tPercent, tParen
+			args, err := pop(id, 1)
+			if err != nil {
+				return "", err
+			}
+			token = [...]string{"+" + args[0], "-" + args[0], args[0] + "%", "(" + args[0] + ")"}[id-0x12]
+		case 0x16: // tMissArg
+		case 0x17: // tStr
+			token = quoteFormulaString(r.str(int(r.u8())))
+		case 0x19: // tAttr
+			flags, n := r.u8(), r.u16()
+			switch {
+			case flags&0x04 != 0: // tAttrChoose, followed by the jump table
+				r.next(2 * (int(n) + 1))
+				continue
+			case flags&0x10 != 0: // tAttrSum
+				args, err := pop(id, 1)
+				if err != nil {
+					return "", err
+				}
+				token = "SUM(" + args[0] + ")"
+			default: // jumps and white space
+				continue
+			}
+		case 0x1C: // tErr
+			token = ErrorCode(r.u8()).String()
+		case 0x1D: // tBool
+			token = "FALSE"
+			if r.u8() != 0 {
+				token = "TRUE"
+			}
+		case 0x1E: // tInt
+			token = strconv.Itoa(int(r.u16()))
+		case 0x1F: // tNum
+			token = formulaNumber(r.f64())
+		default:
+			if id < 0x20 || id >= 0x80 {
+				return "", fmt.Errorf("%w: unknown token 0x%02X", errFormula, id)
+			}
+			var err error
+			// the operand class of the token is in the bits 0x60
+			switch id&0x1F | 0x20 {
+			case 0x20: // tArray
+				r.next(7)
+				token = arrayConstant(ex)
+			case 0x21: // tFunc
+				f, ok := functions[r.u16()]
+				if !ok || f.args < 0 {
+					return "", fmt.Errorf("%w: unknown function", errFormula)
+				}
+				args, err := pop(id, f.args)
+				if err != nil {
+					return "", err
+				}
+				token = f.name + "(" + strings.Join(args, ",") + ")"
+			case 0x22: // tFuncVar
+				n, index := int(r.u8()&0x7F), r.u16()&0x7FFF
+				args, err := pop(id, n)
+				if err != nil {
+					return "", err
+				}
+				name := functions[index].name
+				if index == 255 && n > 0 {
+					name, args = args[0], args[1:]
+				} else if name == "" {
+					return "", fmt.Errorf("%w: unknown function %d", errFormula, index)
+				}
+				token = name + "(" + strings.Join(args, ",") + ")"
+			case 0x23: // tName
+				index := r.u16()
+				r.u16()
+				token, err = w.definedName(index)
+			case 0x24: // tRef
+				token = cellRef(r.u16(), r.u16())
+			case 0x25: // tArea
+				token = areaRef(r.u16(), r.u16(), r.u16(), r.u16())
+			case 0x26: // tMemArea, the areas are in the extra data
+				r.next(6)
+				ex.next(8 * int(ex.u16()))
+				continue
+			case 0x27, 0x28: // tMemErr, tMemNoMem
+				r.next(6)
+				continue
+			case 0x29, 0x2E, 0x2F: // tMemFunc, tMemAreaN, tMemNoMemN
+				r.u16()
+				continue
+			case 0x2A: // tRefErr
+				r.next(4)
+				token = "#REF!"
+			case 0x2B: // tAreaErr
+				r.next(8)
+				token = "#REF!"
+			case 0x2C: // tRefN
+				token = cellRef(relativeRef(row, col, r.u16(), r.u16()))
+			case 0x2D: // tAreaN
+				r1, r2, c1, c2 := r.u16(), r.u16(), r.u16(), r.u16()
+				r1, c1 = relativeRef(row, col, r1, c1)
+				r2, c2 = relativeRef(row, col, r2, c2)
+				token = areaRef(r1, r2, c1, c2)
+			case 0x39: // tNameX
+				ixti, index := r.u16(), r.u16()
+				r.u16()
+				token, err = w.externName(ixti, index)
+			case 0x3A: // tRef3d
+				token, err = w.sheetPrefix(r.u16())
+				token += cellRef(r.u16(), r.u16())
+			case 0x3B: // tArea3d
+				token, err = w.sheetPrefix(r.u16())
+				token += areaRef(r.u16(), r.u16(), r.u16(), r.u16())
+			case 0x3C: // tRefErr3d
+				token, err = w.sheetPrefix(r.u16())
+				r.next(4)
+				token += "#REF!"
+			case 0x3D: // tAreaErr3d
+				token, err = w.sheetPrefix(r.u16())
+				r.next(8)
+				token += "#REF!"
+			default:
+				return "", fmt.Errorf("%w: unknown token 0x%02X", errFormula, id)
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		if r.err != nil || ex.err != nil {
+			return "", fmt.Errorf("%w: token 0x%02X: %v", errFormula, id, io.ErrUnexpectedEOF)
+		}
+		stack = append(stack, token)
+	}
+	if r.err != nil {
+		return "", fmt.Errorf("%w: %v", errFormula, io.ErrUnexpectedEOF)
+	}
+	if len(stack) != 1 {
+		return "", fmt.Errorf("%w: %d operands left", errFormula, len(stack))
+	}
+	return stack[0], nil
+}
+
+// ptgReader reads the fields of formula tokens, reading past the end sets err and gives zeros
+type ptgReader struct {
+	bts []byte
+	pos int
+	err error
+}
+
+func (r *ptgReader) next(n int) []byte {
+	if r.err != nil || r.pos+n > len(r.bts) {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	r.pos += n
+	return r.bts[r.pos-n : r.pos]
+}
+
+func (r *ptgReader) u8() byte {
+	return r.next(1)[0]
+}
+
+func (r *ptgReader) u16() uint16 {
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *ptgReader) f64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.next(8)))
+}
+
+// str reads the flags and the characters of a string
+func (r *ptgReader) str(count int) string {
+	if r.u8()&0x1 != 0 {
+		bts := r.next(2 * count)
+		chars := make([]uint16, count)
+		for i := range chars {
+			chars[i] = binary.LittleEndian.Uint16(bts[2*i:])
+		}
+		return string(utf16.Decode(chars))
+	}
+	runes := make([]rune, count)
+	for i, b := range r.next(count) {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// arrayConstant reads the next constant array of the extra data, like {1,2;"a",TRUE}
+func arrayConstant(ex *ptgReader) string {
+	cols, rows := int(ex.u8())+1, int(ex.u16())+1
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < rows && ex.err == nil; i++ {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		for j := 0; j < cols && ex.err == nil; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			switch ex.u8() {
+			case 0x00: // empty
+				ex.next(8)
+			case 0x01:
+				b.WriteString(formulaNumber(ex.f64()))
+			case 0x02:
+				b.WriteString(quoteFormulaString(ex.str(int(ex.u16()))))
+			case 0x04:
+				if ex.next(8)[0] != 0 {
+					b.WriteString("TRUE")
+				} else {
+					b.WriteString("FALSE")
+				}
+			case 0x10:
+				b.WriteString(ErrorCode(ex.next(8)[0]).String())
+			default:
+				ex.err = errFormula
+			}
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// definedName returns the name of the NAME record, counted from 1
+func (w *WorkBook) definedName(index uint16) (string, error) {
+	if index == 0 || int(index) > len(w.names) || w.names[index-1] == "" {
+		return "", fmt.Errorf("%w: unknown name %d", errFormula, index)
+	}
+	return w.names[index-1], nil
+}
+
+// externName returns the name of a tNameX token, a function of an add-in or a name of another workbook
+func (w *WorkBook) externName(ixti, index uint16) (string, error) {
+	book, _, err := w.externSheet(ixti)
+	if err != nil {
+		return "", err
+	}
+	if book.self {
+		return w.definedName(index)
+	}
+	if index == 0 || int(index) > len(book.names) || book.names[index-1] == "" {
+		return "", fmt.Errorf("%w: unknown external name %d", errFormula, index)
+	}
+	name := book.names[index-1]
+	if book.addin {
+		// the functions added after Excel 2003
+		return strings.TrimPrefix(name, "_xlfn."), nil
+	}
+	return quoteSheetName(book.file) + "!" + name, nil
+}
+
+// externSheet returns the entry of the EXTERNSHEET record and the workbook it refers to
+func (w *WorkBook) externSheet(ixti uint16) (*supBook, xti, error) {
+	if int(ixti) >= len(w.externSheets) {
+		return nil, xti{}, fmt.Errorf("%w: unknown sheet reference %d", errFormula, ixti)
+	}
+	x := w.externSheets[ixti]
+	if int(x.Book) >= len(w.supBooks) || w.supBooks[x.Book].broken {
+		return nil, x, fmt.Errorf("%w: unknown workbook %d", errFormula, x.Book)
+	}
+	return w.supBooks[x.Book], x, nil
+}
+
+// sheetPrefix returns the sheets of a 3D reference like Sheet2! or 'Jan:Mar'!, #REF! for deleted sheets
+func (w *WorkBook) sheetPrefix(ixti uint16) (string, error) {
+	book, x, err := w.externSheet(ixti)
+	if err != nil {
+		return "", err
+	}
+	sheets := book.sheets
+	if book.self {
+		sheets = make([]string, len(w.sheets))
+		for i, s := range w.sheets {
+			sheets[i] = s.Name
+		}
+	}
+	if int(x.First) >= len(sheets) || int(x.Last) >= len(sheets) {
+		return "#REF!", nil
+	}
+	name := sheets[x.First]
+	if x.Last != x.First {
+		name += ":" + sheets[x.Last]
+	}
+	if !book.self {
+		name = "[" + book.file + "]" + name
+	}
+	return quoteSheetName(name) + "!", nil
+}
+
+// quoteSheetName quotes a sheet name that is not a plain word or looks like a cell reference
+func quoteSheetName(name string) string {
+	plain := name != ""
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '.' || r == '[' || r == ']' || i > 0 && unicode.IsDigit(r)) {
+			plain = false
+		}
+	}
+	letters := strings.TrimLeftFunc(name, func(r rune) bool { return r < 0x80 && unicode.IsLetter(r) })
+	if len(name)-len(letters) <= 3 && letters != "" && strings.TrimLeft(letters, "0123456789") == "" {
+		// like A1 or IV100
+		plain = false
+	}
+	if plain {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// externFileName returns the file name of the encoded path of an external workbook
+func externFileName(path string) string {
+	if i := strings.LastIndexFunc(path, func(r rune) bool { return r < 0x20 || r == '/' || r == '\\' }); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// relativeRef resolves the offsets of a tRefN token, relative to the cell of the formula
+func relativeRef(row, col, rw, cl uint16) (uint16, uint16) {
+	if cl&0x8000 != 0 {
+		rw += row
+	}
+	if cl&0x4000 != 0 {
+		cl = cl&0xFF00 | uint16(byte(cl)+byte(col))
+	}
+	return rw, cl
+}
+
+// cellRef returns the A1 reference of a cell, the bits 0x4000 and 0x8000 of the column mark the column
+// and the row as relative, otherwise they are absolute like $A$1
+func cellRef(rw, cl uint16) string {
+	return columnRef(cl) + rowRef(rw, cl)
+}
+
+func columnRef(cl uint16) string {
+	name := ""
+	for c := int(cl & 0xFF); c >= 0; c = c/26 - 1 {
+		name = string(rune('A'+c%26)) + name
+	}
+	if cl&0x4000 == 0 {
+		return "$" + name
+	}
+	return name
+}
+
+func rowRef(rw, cl uint16) string {
+	if cl&0x8000 == 0 {
+		return "$" + strconv.Itoa(int(rw)+1)
+	}
+	return strconv.Itoa(int(rw) + 1)
+}
+
+// areaRef returns the A1 reference of an area, A:B for whole columns and 1:2 for whole rows
+func areaRef(r1, r2, c1, c2 uint16) string {
+	switch {
+	case r1 == 0 && r2 == 0xFFFF:
+		return columnRef(c1) + ":" + columnRef(c2)
+	case c1&0xFF == 0 && c2&0xFF == 0xFF:
+		return rowRef(r1, c1) + ":" + rowRef(r2, c2)
+	}
+	return cellRef(r1, c1) + ":" + cellRef(r2, c2)
+}
+
+func quoteFormulaString(str string) string {
+	return `"` + strings.ReplaceAll(str, `"`, `""`) + `"`
+}
+
+// formulaNumber formats a number constant, with an exponent only for very small or large numbers
+func formulaNumber(f float64) string {
+	if a := math.Abs(f); a != 0 && (a < 1e-9 || a >= 1e15) {
+		return strings.ToUpper(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
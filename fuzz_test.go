@@ -0,0 +1,101 @@
+//go:build go1.18
+
+package xls
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+)
+
+// tableStreams returns the file, the workbook stream and the first sheet's records of Table.xls
+func tableStreams(f *testing.F) (file, book, sheet []byte) {
+	file, err := os.ReadFile("Table.xls")
+	if err != nil {
+		f.Fatal(err)
+	}
+	ole, err := openOleFile(bytes.NewReader(file))
+	if err != nil {
+		f.Fatal(err)
+	}
+	book, err = ole.read(ole.find("Workbook"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	if err := wb.Parse(bytes.NewReader(book)); err != nil {
+		f.Fatal(err)
+	}
+	return file, book, book[wb.sheets[0].bs.Filepos:]
+}
+
+// readSheet reads the cells of the first rows of the sheet the ways a caller would
+func readSheet(sheet *WorkSheet) {
+	for i := 0; i <= int(sheet.MaxRow) && i < 256; i++ {
+		row := sheet.Row(i)
+		if row == nil {
+			continue
+		}
+		for j := row.FirstCol(); j <= row.LastCol() && j < 256; j++ {
+			row.Col(j)
+			c := row.Cell(j)
+			c.Float()
+			c.Time()
+		}
+		for _, ch := range row.cols {
+			ch.String(sheet.wb)
+			ch.cells(sheet.wb)
+		}
+	}
+}
+
+func FuzzOpenReader(f *testing.F) {
+	file, _, _ := tableStreams(f)
+	f.Add(file)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		wb, err := OpenReader(bytes.NewReader(data), "")
+		if err != nil || wb == nil {
+			return
+		}
+		for i := 0; i < wb.NumSheets(); i++ {
+			if sheet, err := wb.GetSheetE(i); err == nil {
+				readSheet(sheet)
+			}
+		}
+		wb.ReadAllCells(256)
+	})
+}
+
+func FuzzWorkBookParse(f *testing.F) {
+	_, book, _ := tableStreams(f)
+	f.Add(book)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		wb := &WorkBook{Formats: make(map[uint16]*Format), rs: sectionOf(data)}
+		if err := wb.Parse(bytes.NewReader(data)); err != nil {
+			return
+		}
+		for i := 0; i < wb.NumSheets(); i++ {
+			if sheet, err := wb.GetSheetE(i); err == nil {
+				readSheet(sheet)
+			}
+		}
+	})
+}
+
+func FuzzWorkSheetParse(f *testing.F) {
+	_, book, sheet := tableStreams(f)
+	f.Add(sheet)
+	f.Add(append(formulaTokens(0, 0, fields(byte(0x25), uint16(0), uint16(2), uint16(0xC000), uint16(0xC001),
+		byte(0x42), byte(1), uint16(4), byte(0x60), [7]byte{}, byte(0x05)), fields(byte(0), uint16(0), byte(1), 1.0)), record(0x0a)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		wb := &WorkBook{Formats: make(map[uint16]*Format)}
+		if err := wb.Parse(bytes.NewReader(book)); err != nil {
+			t.Fatal(err)
+		}
+		ws := &WorkSheet{wb: wb, bs: new(boundsheet)}
+		if err := ws.parse(context.Background(), bytes.NewReader(data), 0); err == nil {
+			readSheet(ws)
+		}
+	})
+}
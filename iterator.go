@@ -0,0 +1,166 @@
+package xls
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+)
+
+// maxPendingRows is the number of decoded rows after which the iterator hands out
+// the finished rows even if the sheet has no DBCELL records closing the row blocks
+const maxPendingRows = 128
+
+// RowIterator reads the rows of a sheet one row block at a time, see WorkSheet.Rows
+type RowIterator struct {
+	// the sheet decoding the records of the current row block
+	block  *WorkSheet
+	buf    io.Reader
+	offset int64
+	// the hyperlinks read so far, they follow the cells in the sheet
+	links []*HyperLink
+	// the decoded rows ready to be handed out, in order
+	ready   []*Row
+	row     *Row
+	err     error
+	done    bool
+	records int64
+}
+
+// Rows returns an iterator over the rows of the sheet in ascending order,
+// it decodes the records of the sheet while iterating and keeps only the current row block in memory.
+// Rows without any cell are skipped. The hyperlinks follow the cells in the sheet, so the rows do not
+// have them, see RowIterator.HyperLinks. Merged cells are not filled, see Options.FillMergedCells.
+//
+//	it := sheet.Rows()
+//	for it.Next() {
+//		row := it.Row()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+func (w *WorkSheet) Rows() *RowIterator {
+	if w.wb.writable {
+		// the cells of a new workbook are only in memory, flush takes the rows of a copy
+		it := &RowIterator{block: &WorkSheet{bs: w.bs, wb: w.wb, Name: w.Name, rows: make(map[uint16]*Row)}, done: true}
+		for i, row := range w.rows {
+			it.block.rows[i] = row
+		}
+		it.flush(math.MaxInt32)
+		return it
+	}
+	offset := int64(w.bs.Filepos)
+	return &RowIterator{
+		block:  &WorkSheet{bs: w.bs, wb: w.wb, Name: w.Name, rows: make(map[uint16]*Row)},
+		buf:    w.wb.section(offset),
+		offset: offset,
+	}
+}
+
+// Next advances to the next row, it returns false at the end of the sheet or on an error
+func (it *RowIterator) Next() bool {
+	for len(it.ready) == 0 {
+		if it.done || it.err != nil {
+			it.row = nil
+			return false
+		}
+		it.readBlock()
+	}
+	it.row, it.ready = it.ready[0], it.ready[1:]
+	return true
+}
+
+// Row returns the current row
+func (it *RowIterator) Row() *Row {
+	return it.row
+}
+
+// HyperLinks returns the hyperlinks read so far, they come after the cells in the sheet
+// so they are all read once Next returned false
+func (it *RowIterator) HyperLinks() []*HyperLink {
+	return it.links
+}
+
+// Err returns the error that stopped the iteration, a *ParseError for a broken record
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// readBlock decodes records until a row block is finished
+func (it *RowIterator) readBlock() {
+	var preBof *bof
+	for {
+		b, bts, err := readRecord(it.buf, it.offset)
+		if err != nil {
+			it.err = err
+			return
+		}
+		it.records++
+		if err := it.block.wb.opts.checkRecords(it.records); err != nil {
+			it.err = &ParseError{RecordID: b.ID, Offset: it.offset, Err: err}
+			return
+		}
+		it.offset += 4 + int64(b.Size)
+		switch b.ID {
+		case 0xa: //EOF
+			it.done = true
+			it.flush(math.MaxInt32)
+			return
+		case 0xd7: //DBCELL
+			it.flush(math.MaxInt32)
+			return
+		case 0x1b8: //HYPERLINK
+			hy, err := parseHyperLink(bytes.NewReader(bts), b)
+			if err != nil {
+				it.err = &ParseError{RecordID: b.ID, Offset: it.offset - 4 - int64(b.Size), Err: err}
+				return
+			}
+			if hy != nil {
+				it.links = append(it.links, hy)
+			}
+			continue
+		case 0xe5: //MERGEDCELLS
+			// the rows are handed out before the merged ranges are read, so they are never filled
+			continue
+		}
+		if preBof, err = it.block.parseBof(bytes.NewReader(bts), b, preBof); err != nil {
+			it.err = &ParseError{RecordID: b.ID, Offset: it.offset - 4 - int64(b.Size), Err: err}
+			return
+		}
+		if err := it.block.wb.opts.checkCells(it.block.cells); err != nil {
+			it.err = &ParseError{RecordID: b.ID, Offset: it.offset - 4 - int64(b.Size), Err: err}
+			return
+		}
+		if len(it.block.rows) > maxPendingRows {
+			// the cells come in row order, so only the last row with cells may still grow
+			last := -1
+			for i, row := range it.block.rows {
+				if len(row.cols) > 0 && int(i) > last {
+					last = int(i)
+				}
+			}
+			if it.flush(last); len(it.ready) > 0 {
+				return
+			}
+		}
+	}
+}
+
+// flush moves the decoded rows before the given index to the ready rows
+func (it *RowIterator) flush(before int) {
+	var indexes []int
+	for i := range it.block.rows {
+		if int(i) < before {
+			indexes = append(indexes, int(i))
+		}
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		row := it.block.rows[uint16(i)]
+		delete(it.block.rows, uint16(i))
+		if len(row.cols) > 0 {
+			it.ready = append(it.ready, row)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package xls
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// Metadata is the document information of a workbook,
+// read from the SummaryInformation and DocumentSummaryInformation streams of the file
+type Metadata struct {
+	Title       string
+	Subject     string
+	Author      string
+	Keywords    string
+	Comments    string
+	LastSavedBy string
+	Application string
+	Created     time.Time
+	Modified    time.Time
+	Category    string
+	Manager     string
+	Company     string
+	// Custom holds the user defined properties by name,
+	// the values are string, int32, uint32, float64, bool or time.Time
+	Custom map[string]interface{}
+}
+
+// the format IDs of the property sections, as stored in the file
+var (
+	fmtidSummary     = [16]byte{0xE0, 0x85, 0x9F, 0xF2, 0xF9, 0x4F, 0x68, 0x10, 0xAB, 0x91, 0x08, 0x00, 0x2B, 0x27, 0xB3, 0xD9}
+	fmtidDocSummary  = [16]byte{0x02, 0xD5, 0xCD, 0xD5, 0x9C, 0x2E, 0x1B, 0x10, 0x93, 0x97, 0x08, 0x00, 0x2B, 0x2C, 0xF9, 0xAE}
+	fmtidUserDefined = [16]byte{0x05, 0xD5, 0xCD, 0xD5, 0x9C, 0x2E, 0x1B, 0x10, 0x93, 0x97, 0x08, 0x00, 0x2B, 0x2C, 0xF9, 0xAE}
+)
+
+// the property types
+const (
+	vtI2       = 2
+	vtI4       = 3
+	vtR8       = 5
+	vtBool     = 11
+	vtUI4      = 19
+	vtLPStr    = 30
+	vtLPWStr   = 31
+	vtFileTime = 64
+)
+
+// the property ids of the sections
+const (
+	pidDictionary = 0
+	pidCodepage   = 1
+
+	pidTitle       = 2
+	pidSubject     = 3
+	pidAuthor      = 4
+	pidKeywords    = 5
+	pidComments    = 6
+	pidLastAuthor  = 8
+	pidCreated     = 12
+	pidLastSaved   = 13
+	pidApplication = 18
+
+	pidCategory = 2
+	pidManager  = 14
+	pidCompany  = 15
+)
+
+// propertySection is one section of a property set stream
+type propertySection struct {
+	props map[uint32]interface{}
+	//the names of the user defined properties
+	names map[uint32]string
+}
+
+func (s *propertySection) string(id uint32) string {
+	str, _ := s.props[id].(string)
+	return str
+}
+
+func (s *propertySection) time(id uint32) time.Time {
+	t, _ := s.props[id].(time.Time)
+	return t
+}
+
+// readMetadata fills the metadata from the content of the property set streams,
+// broken streams are ignored as the metadata is optional.
+func (w *WorkBook) readMetadata(summary []byte, docSummary []byte) {
+	if sec := parsePropertySet(summary)[fmtidSummary]; sec != nil {
+		w.Metadata.Title = sec.string(pidTitle)
+		w.Metadata.Subject = sec.string(pidSubject)
+		w.Metadata.Author = sec.string(pidAuthor)
+		w.Metadata.Keywords = sec.string(pidKeywords)
+		w.Metadata.Comments = sec.string(pidComments)
+		w.Metadata.LastSavedBy = sec.string(pidLastAuthor)
+		w.Metadata.Application = sec.string(pidApplication)
+		w.Metadata.Created = sec.time(pidCreated)
+		w.Metadata.Modified = sec.time(pidLastSaved)
+		w.Author = w.Metadata.Author
+	}
+	sections := parsePropertySet(docSummary)
+	if sec := sections[fmtidDocSummary]; sec != nil {
+		w.Metadata.Category = sec.string(pidCategory)
+		w.Metadata.Manager = sec.string(pidManager)
+		w.Metadata.Company = sec.string(pidCompany)
+	}
+	if sec := sections[fmtidUserDefined]; sec != nil && len(sec.names) > 0 {
+		w.Metadata.Custom = make(map[string]interface{})
+		for id, name := range sec.names {
+			if v, ok := sec.props[id]; ok {
+				w.Metadata.Custom[name] = v
+			}
+		}
+	}
+}
+
+// parsePropertySet reads the sections of a property set stream by their format id
+func parsePropertySet(bts []byte) map[[16]byte]*propertySection {
+	res := make(map[[16]byte]*propertySection)
+	if len(bts) < 28 || binary.LittleEndian.Uint16(bts) != 0xFFFE {
+		return res
+	}
+	count := binary.LittleEndian.Uint32(bts[24:])
+	for i := uint32(0); i < count; i++ {
+		pos := 28 + 20*int(i)
+		if pos+20 > len(bts) {
+			break
+		}
+		var fmtid [16]byte
+		copy(fmtid[:], bts[pos:])
+		offset := binary.LittleEndian.Uint32(bts[pos+16:])
+		if int64(offset) < int64(len(bts)) {
+			res[fmtid] = parsePropertySection(bts[offset:])
+		}
+	}
+	return res
+}
+
+func parsePropertySection(bts []byte) *propertySection {
+	sec := &propertySection{props: make(map[uint32]interface{})}
+	if len(bts) < 8 {
+		return sec
+	}
+	if size := binary.LittleEndian.Uint32(bts); size >= 8 && int64(size) < int64(len(bts)) {
+		bts = bts[:size]
+	}
+	count := binary.LittleEndian.Uint32(bts[4:])
+	offsets := make(map[uint32]uint32)
+	for i := uint32(0); i < count; i++ {
+		pos := 8 + 8*int(i)
+		if pos+8 > len(bts) {
+			break
+		}
+		offsets[binary.LittleEndian.Uint32(bts[pos:])] = binary.LittleEndian.Uint32(bts[pos+4:])
+	}
+	codepage := uint16(1252)
+	if offset, ok := offsets[pidCodepage]; ok {
+		if v, ok := readProperty(bts, offset, codepage).(int32); ok {
+			codepage = uint16(v)
+		}
+	}
+	for id, offset := range offsets {
+		switch id {
+		case pidDictionary:
+			sec.names = readDictionary(bts, offset, codepage)
+		case pidCodepage:
+		default:
+			if v := readProperty(bts, offset, codepage); v != nil {
+				sec.props[id] = v
+			}
+		}
+	}
+	return sec
+}
+
+// readProperty reads the typed value at the offset of the section, nil if the type is not supported
+func readProperty(bts []byte, offset uint32, codepage uint16) interface{} {
+	if int64(offset)+8 > int64(len(bts)) {
+		return nil
+	}
+	data := bts[offset+4:]
+	switch binary.LittleEndian.Uint32(bts[offset:]) & 0xFFFF {
+	case vtI2:
+		return int32(int16(binary.LittleEndian.Uint16(data)))
+	case vtI4:
+		return int32(binary.LittleEndian.Uint32(data))
+	case vtUI4:
+		return binary.LittleEndian.Uint32(data)
+	case vtBool:
+		return binary.LittleEndian.Uint16(data) != 0
+	case vtR8:
+		if len(data) >= 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data))
+		}
+	case vtFileTime:
+		if len(data) >= 8 {
+			return fileTime(binary.LittleEndian.Uint64(data))
+		}
+	case vtLPStr:
+		size := binary.LittleEndian.Uint32(data)
+		if int64(size) <= int64(len(data)-4) {
+			return decodePropertyString(data[4:4+size], codepage)
+		}
+	case vtLPWStr:
+		size := binary.LittleEndian.Uint32(data)
+		if int64(size)*2 <= int64(len(data)-4) {
+			return decodePropertyString(data[4:4+2*size], 1200)
+		}
+	}
+	return nil
+}
+
+// readDictionary reads the names of the user defined properties
+func readDictionary(bts []byte, offset uint32, codepage uint16) map[uint32]string {
+	names := make(map[uint32]string)
+	if int64(offset)+4 > int64(len(bts)) {
+		return names
+	}
+	count := binary.LittleEndian.Uint32(bts[offset:])
+	pos := int64(offset) + 4
+	for i := uint32(0); i < count && pos+8 <= int64(len(bts)); i++ {
+		id := binary.LittleEndian.Uint32(bts[pos:])
+		size := int64(binary.LittleEndian.Uint32(bts[pos+4:]))
+		pos += 8
+		if codepage == 1200 {
+			size *= 2
+		}
+		if pos+size > int64(len(bts)) {
+			break
+		}
+		names[id] = decodePropertyString(bts[pos:pos+size], codepage)
+		pos += size
+		if codepage == 1200 && pos%4 != 0 {
+			pos += 4 - pos%4
+		}
+	}
+	return names
+}
+
+// decodePropertyString decodes the bytes of a string property without its terminating zeros
+func decodePropertyString(bts []byte, codepage uint16) string {
+	if codepage == 1200 {
+		chars := make([]uint16, len(bts)/2)
+		for i := range chars {
+			chars[i] = binary.LittleEndian.Uint16(bts[2*i:])
+		}
+		for len(chars) > 0 && chars[len(chars)-1] == 0 {
+			chars = chars[:len(chars)-1]
+		}
+		return string(utf16.Decode(chars))
+	}
+	for len(bts) > 0 && bts[len(bts)-1] == 0 {
+		bts = bts[:len(bts)-1]
+	}
+	return decodeCodepage(bts, codepage)
+}
+
+// fileTime converts a FILETIME, the 100-nanosecond intervals since 1601, to time
+func fileTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	const unixEpoch = 116444736000000000
+	ticks := int64(ft) - unixEpoch
+	return time.Unix(ticks/1e7, ticks%1e7*100).UTC()
+}
@@ -0,0 +1,237 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/extrame/ole2"
+)
+
+// ErrNotXls is returned when the file is not an OLE2 compound document
+var ErrNotXls = errors.New("xls: not an excel file")
+
+// oleFile is a compound document read with ole2. The ole2 package follows sector chains without checking
+// them: a sector beyond the allocation table panics with an index out of range, and a cyclic chain
+// or DIFAT chain loops forever, so every chain is checked before ole2 follows it.
+type oleFile struct {
+	ole    *ole2.Ole
+	r      io.ReadSeeker
+	header cfbHeader
+	// the number of sectors after the header
+	sectors uint32
+	dir     []*ole2.File
+	root    *ole2.File
+}
+
+// recoverOle turns a panic of the ole2 package on a broken file into an error
+func recoverOle(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("xls: broken compound file: %v", r)
+	}
+}
+
+func openOleFile(r io.ReadSeeker) (f *oleFile, err error) {
+	defer recoverOle(&err)
+	f = &oleFile{r: r}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h := &f.header
+	if err := binary.Read(r, binary.LittleEndian, h); err != nil {
+		return nil, ErrNotXls
+	}
+	if h.Signature != 0xE11AB1A1E011CFD0 || h.ByteOrder != 0xFFFE {
+		return nil, ErrNotXls
+	}
+	// ole2 only reads 512 bytes sectors and 64 bytes mini sectors
+	if h.SectorShift != 9 || h.MiniSectorShift != 6 {
+		return nil, fmt.Errorf("xls: unsupported sector size 2^%d", h.SectorShift)
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	f.sectors = uint32((size - 1) / 512)
+	if err := f.checkDIFAT(); err != nil {
+		return nil, err
+	}
+	if h.NumMiniFAT > f.sectors {
+		return nil, fmt.Errorf("xls: %d mini FAT sectors in %d sectors", h.NumMiniFAT, f.sectors)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if f.ole, err = ole2.Open(r, ""); err != nil {
+		return nil, err
+	}
+	if err := checkChain(h.DirStart, f.ole.SecID); err != nil {
+		return nil, fmt.Errorf("xls: directory: %v", err)
+	}
+	if f.dir, err = f.ole.ListDir(); err != nil {
+		return nil, err
+	}
+	for _, file := range f.dir {
+		if fileName(file) == "Root Entry" {
+			f.root = file
+		}
+	}
+	return f, nil
+}
+
+// checkDIFAT checks the chain of the DIFAT sectors, which ole2 follows until its end without a loop check
+func (f *oleFile) checkDIFAT() error {
+	seen := make(map[uint32]bool)
+	for sid := f.header.DIFATStart; sid != cfbEndOfChain; {
+		if sid >= f.sectors || seen[sid] || uint32(len(seen)) >= f.header.NumDIFAT {
+			return fmt.Errorf("xls: broken DIFAT chain at sector %d", sid)
+		}
+		seen[sid] = true
+		if _, err := f.r.Seek(512+int64(sid)*512+508, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Read(f.r, binary.LittleEndian, &sid); err != nil {
+			return fmt.Errorf("xls: DIFAT sector: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkChain checks that the chain of sectors ends within the allocation table without a loop
+func checkChain(start uint32, table []uint32) error {
+	_, err := chain(start, table)
+	return err
+}
+
+// chain returns the sectors of the chain starting at start
+func chain(start uint32, table []uint32) ([]uint32, error) {
+	var sectors []uint32
+	seen := make([]bool, len(table))
+	for sid := start; sid != cfbEndOfChain; sid = table[sid] {
+		if int64(sid) >= int64(len(table)) || seen[sid] {
+			return nil, fmt.Errorf("broken chain at sector %d", sid)
+		}
+		seen[sid] = true
+		sectors = append(sectors, sid)
+	}
+	return sectors, nil
+}
+
+// fileName returns the name of a directory entry, empty if its length is broken
+func fileName(file *ole2.File) string {
+	n := int(file.Bsize)/2 - 1
+	if n <= 0 || n > len(file.NameBts) {
+		return ""
+	}
+	return string(utf16.Decode(file.NameBts[:n]))
+}
+
+// find returns the first stream with one of the names, in the order of the names
+func (f *oleFile) find(names ...string) *ole2.File {
+	for _, name := range names {
+		for _, file := range f.dir {
+			if file.Type == ole2.USERSTREAM && fileName(file) == name {
+				return file
+			}
+		}
+	}
+	return nil
+}
+
+// stream returns a reader of the stream. A stream in regular sectors is read in place from the file,
+// only a short stream in the mini stream is loaded into memory.
+func (f *oleFile) stream(file *ole2.File) (*io.SectionReader, error) {
+	if file.Size < f.header.MiniCutoff {
+		bts, err := f.read(file)
+		if err != nil {
+			return nil, err
+		}
+		return io.NewSectionReader(bytes.NewReader(bts), 0, int64(len(bts))), nil
+	}
+	sectors, err := chain(file.Sstart, f.ole.SecID)
+	if err == nil && int64(len(sectors))*512 < int64(file.Size) {
+		err = fmt.Errorf("%d sectors for %d bytes", len(sectors), file.Size)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xls: stream %s: %v", fileName(file), err)
+	}
+	r, ok := f.r.(io.ReaderAt)
+	if !ok {
+		r = &seekReaderAt{r: f.r}
+	}
+	return io.NewSectionReader(&sectorReader{r: r, sectors: sectors}, 0, int64(file.Size)), nil
+}
+
+// sectorReader reads a stream at its offsets through the sectors of its chain
+type sectorReader struct {
+	r       io.ReaderAt
+	sectors []uint32
+}
+
+func (s *sectorReader) ReadAt(p []byte, off int64) (n int, err error) {
+	for len(p) > 0 {
+		i := off / 512
+		if i >= int64(len(s.sectors)) {
+			return n, io.EOF
+		}
+		pos := off % 512
+		size := 512 - pos
+		if size > int64(len(p)) {
+			size = int64(len(p))
+		}
+		m, err := s.r.ReadAt(p[:size], 512+int64(s.sectors[i])*512+pos)
+		n += m
+		if m < int(size) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		off += size
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// seekReaderAt reads at offsets of a reader without ReadAt, one read at a time
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.r, p)
+}
+
+// read loads the content of the stream after checking its chains
+func (f *oleFile) read(file *ole2.File) (bts []byte, err error) {
+	defer recoverOle(&err)
+	if file.Size < f.header.MiniCutoff {
+		if f.root == nil {
+			return nil, fmt.Errorf("xls: no root entry for the short stream %s", fileName(file))
+		}
+		err = checkChain(f.root.Sstart, f.ole.SecID)
+		if err == nil {
+			err = checkChain(file.Sstart, f.ole.SSecID)
+		}
+	} else {
+		err = checkChain(file.Sstart, f.ole.SecID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xls: stream %s: %v", fileName(file), err)
+	}
+	bts = make([]byte, file.Size)
+	if _, err := io.ReadFull(f.ole.OpenFile(file, f.root), bts); err != nil {
+		return nil, fmt.Errorf("xls: stream %s: %v", fileName(file), err)
+	}
+	return bts, nil
+}
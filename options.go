@@ -0,0 +1,61 @@
+package xls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimit is returned when a file exceeds one of the limits of Options
+var ErrLimit = errors.New("xls: limit exceeded")
+
+// Options controls how OpenReaderContext reads a workbook, the zero value sets no limits
+type Options struct {
+	// Charset decodes the byte strings of BIFF5 files instead of the codepage of the file, see Open
+	Charset string
+	// MaxRecords limits the records of the workbook stream and of each sheet
+	MaxRecords int
+	// MaxSSTSize limits the strings of the shared string table
+	MaxSSTSize int
+	// MaxCellsPerSheet limits the cells of each sheet
+	MaxCellsPerSheet int
+	// MaxStringLength limits the characters of each string
+	MaxStringLength int
+	// FillMergedCells makes Row.Col and Row.Cell return the value of a merged range for all the cells it covers,
+	// otherwise only its first cell has the value. Rows of WorkSheet.Rows are not filled.
+	FillMergedCells bool
+}
+
+// ctxCheckInterval is the number of records read between checks of the context, starting with the first
+const ctxCheckInterval = 1024
+
+func limitError(what string, n int64, max int) error {
+	return fmt.Errorf("%w: %d %s, at most %d allowed", ErrLimit, n, what, max)
+}
+
+func (o *Options) checkRecords(n int64) error {
+	if o.MaxRecords > 0 && n > int64(o.MaxRecords) {
+		return limitError("records", n, o.MaxRecords)
+	}
+	return nil
+}
+
+func (o *Options) checkSST(n uint32) error {
+	if o.MaxSSTSize > 0 && int64(n) > int64(o.MaxSSTSize) {
+		return limitError("shared strings", int64(n), o.MaxSSTSize)
+	}
+	return nil
+}
+
+func (o *Options) checkCells(n int64) error {
+	if o.MaxCellsPerSheet > 0 && n > int64(o.MaxCellsPerSheet) {
+		return limitError("cells in the sheet", n, o.MaxCellsPerSheet)
+	}
+	return nil
+}
+
+func (o *Options) checkString(n uint16) error {
+	if o.MaxStringLength > 0 && int(n) > o.MaxStringLength {
+		return limitError("characters in a string", int64(n), o.MaxStringLength)
+	}
+	return nil
+}
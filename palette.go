@@ -0,0 +1,85 @@
+package xls
+
+import "fmt"
+
+// RGB is a color given by its red, green and blue parts
+type RGB struct {
+	R, G, B uint8
+}
+
+// Hex returns the color as a hex string like FF0000 for red
+func (c RGB) Hex() string {
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// HTML returns the color as a hex string with a leading #, like #FF0000 for red
+func (c RGB) HTML() string {
+	return "#" + c.Hex()
+}
+
+// the color indexes of the system colors, which resolve to the default window colors
+const (
+	colorWindowText       = 0x40
+	colorWindowBackground = 0x41
+	colorFontAuto         = 0x7FFF
+)
+
+// the first color index of the palette, the indexes before it are the fixed colors
+const paletteFirst = 8
+
+// fixedColors are the colors of the indexes 0 to 7, which can not be changed
+var fixedColors = [paletteFirst]RGB{
+	{0x00, 0x00, 0x00}, {0xFF, 0xFF, 0xFF}, {0xFF, 0x00, 0x00}, {0x00, 0xFF, 0x00},
+	{0x00, 0x00, 0xFF}, {0xFF, 0xFF, 0x00}, {0xFF, 0x00, 0xFF}, {0x00, 0xFF, 0xFF},
+}
+
+// defaultPalette is the palette of the indexes 8 to 63 when the workbook has no PALETTE record
+var defaultPalette = [56]RGB{
+	{0x00, 0x00, 0x00}, {0xFF, 0xFF, 0xFF}, {0xFF, 0x00, 0x00}, {0x00, 0xFF, 0x00},
+	{0x00, 0x00, 0xFF}, {0xFF, 0xFF, 0x00}, {0xFF, 0x00, 0xFF}, {0x00, 0xFF, 0xFF},
+	{0x80, 0x00, 0x00}, {0x00, 0x80, 0x00}, {0x00, 0x00, 0x80}, {0x80, 0x80, 0x00},
+	{0x80, 0x00, 0x80}, {0x00, 0x80, 0x80}, {0xC0, 0xC0, 0xC0}, {0x80, 0x80, 0x80},
+	{0x99, 0x99, 0xFF}, {0x99, 0x33, 0x66}, {0xFF, 0xFF, 0xCC}, {0xCC, 0xFF, 0xFF},
+	{0x66, 0x00, 0x66}, {0xFF, 0x80, 0x80}, {0x00, 0x66, 0xCC}, {0xCC, 0xCC, 0xFF},
+	{0x00, 0x00, 0x80}, {0xFF, 0x00, 0xFF}, {0xFF, 0xFF, 0x00}, {0x00, 0xFF, 0xFF},
+	{0x80, 0x00, 0x80}, {0x80, 0x00, 0x00}, {0x00, 0x80, 0x80}, {0x00, 0x00, 0xFF},
+	{0x00, 0xCC, 0xFF}, {0xCC, 0xFF, 0xFF}, {0xCC, 0xFF, 0xCC}, {0xFF, 0xFF, 0x99},
+	{0x99, 0xCC, 0xFF}, {0xFF, 0x99, 0xCC}, {0xCC, 0x99, 0xFF}, {0xFF, 0xCC, 0x99},
+	{0x33, 0x66, 0xFF}, {0x33, 0xCC, 0xCC}, {0x99, 0xCC, 0x00}, {0xFF, 0xCC, 0x00},
+	{0xFF, 0x99, 0x00}, {0xFF, 0x66, 0x00}, {0x66, 0x66, 0x99}, {0x96, 0x96, 0x96},
+	{0x00, 0x33, 0x66}, {0x33, 0x99, 0x66}, {0x00, 0x33, 0x00}, {0x33, 0x33, 0x00},
+	{0x99, 0x33, 0x00}, {0x99, 0x33, 0x66}, {0x33, 0x33, 0x99}, {0x33, 0x33, 0x33},
+}
+
+// Palette returns the colors of the indexes 8 to 63, from the PALETTE record or the default ones
+func (w *WorkBook) Palette() []RGB {
+	palette := append([]RGB(nil), defaultPalette[:]...)
+	copy(palette, w.palette)
+	return palette
+}
+
+// Color returns the color of a color index of fonts, borders and fills, false if the index has no color.
+// The system colors of the window text and the automatic font color are black, the window background is white.
+func (w *WorkBook) Color(index uint16) (RGB, bool) {
+	switch {
+	case index < paletteFirst:
+		return fixedColors[index], true
+	case int(index-paletteFirst) < len(w.palette):
+		return w.palette[index-paletteFirst], true
+	case int(index-paletteFirst) < len(defaultPalette):
+		return defaultPalette[index-paletteFirst], true
+	case index == colorWindowText || index == colorFontAuto:
+		return RGB{0x00, 0x00, 0x00}, true
+	case index == colorWindowBackground:
+		return RGB{0xFF, 0xFF, 0xFF}, true
+	}
+	return RGB{}, false
+}
+
+// ColorHex returns the color of the index as a hex string like FF0000, empty if the index has no color
+func (w *WorkBook) ColorHex(index uint16) string {
+	if c, ok := w.Color(index); ok {
+		return c.Hex()
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package xls
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// TextRun is a part of a string shown in its own font, from the formatting runs of a shared string
+type TextRun struct {
+	// Start is the index of the first character of the run in the runes of the string
+	Start int
+	Text  string
+	// FontIndex is the index of the FONT record, Font is nil if there is no such font
+	FontIndex uint16
+	Font      *Font
+}
+
+// richData holds the formatting runs and the phonetic block of a shared string as stored in the file
+type richData struct {
+	runs     []byte
+	phonetic []byte
+}
+
+// textRuns decodes the formatting runs, which count the characters of the string in UTF-16 code units
+func (r *richData) textRuns(wb *WorkBook, str string) []TextRun {
+	if len(r.runs) < 4 {
+		return nil
+	}
+	runes := []rune(str)
+	// the rune index of each UTF-16 code unit
+	index := make([]int, 0, len(runes)+1)
+	for i, c := range runes {
+		index = append(index, i)
+		if c >= 0x10000 {
+			index = append(index, i)
+		}
+	}
+	index = append(index, len(runes))
+	var res []TextRun
+	for i := 0; i+4 <= len(r.runs); i += 4 {
+		unit := int(binary.LittleEndian.Uint16(r.runs[i:]))
+		if unit >= len(index) {
+			unit = len(index) - 1
+		}
+		if n := len(res); n > 0 && index[unit] < res[n-1].Start {
+			continue
+		}
+		font := binary.LittleEndian.Uint16(r.runs[i+2:])
+		res = append(res, TextRun{Start: index[unit], FontIndex: font, Font: wb.Font(font)})
+	}
+	for i := range res {
+		end := len(runes)
+		if i+1 < len(res) {
+			end = res[i+1].Start
+		}
+		res[i].Text = string(runes[res[i].Start:end])
+	}
+	return res
+}
+
+// phoneticText decodes the text of the phonetic block, the ExtRst structure of MS-XLS
+func (r *richData) phoneticText() string {
+	// reserved, size, font, settings, runs, characters, then the string with its own length
+	if len(r.phonetic) < 14 {
+		return ""
+	}
+	count := int(binary.LittleEndian.Uint16(r.phonetic[12:]))
+	if 14+2*count > len(r.phonetic) {
+		return ""
+	}
+	chars := make([]uint16, count)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(r.phonetic[14+2*i:])
+	}
+	return string(utf16.Decode(chars))
+}
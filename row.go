@@ -10,30 +10,96 @@ type rowInfo struct {
 	Flags    uint32
 }
 
+// the bits of the ROW flags, the XF index is in the high word
+const (
+	rowOutlineMask  = 0x00000007
+	rowCollapsed    = 0x00000010
+	rowHidden       = 0x00000020
+	rowCustomHeight = 0x00000040
+	rowHasXf        = 0x00000080
+	rowXfShift      = 16
+	rowXfMask       = 0x0FFF
+)
+
+// the bits of the DEFAULTROWHEIGHT flags
+const (
+	defRowCustomHeight = 0x0001
+	defRowHidden       = 0x0002
+)
+
+// the bit of the height telling in old formats that the row has the default height
+const rowDefaultHeight = 0x8000
+
+// the height of rows in twips when the sheet has no DEFAULTROWHEIGHT record
+const defaultRowTwips = 255
+
 // Row the data of one row
 type Row struct {
-	wb   *WorkBook
-	info *rowInfo
-	cols map[uint16]contentHandler
+	wb    *WorkBook
+	sheet *WorkSheet
+	info  *rowInfo
+	cols  map[uint16]contentHandler
 }
 
 // Col gets the Nth column of the row, if has not, return nil.
 //Suggest use Has function to test it.
 func (r *Row) Col(i int) string {
+	r, i = r.source(i)
+	if ch, n := r.content(i); ch != nil {
+		if strs := ch.String(r.wb); n < len(strs) {
+			return strs[n]
+		}
+	}
+	return ""
+}
+
+// Cell gets the typed value of the Nth column of the row, a blank cell if it has not.
+func (r *Row) Cell(i int) Cell {
+	r, i = r.source(i)
+	if ch, n := r.content(i); ch != nil {
+		if cells := ch.cells(r.wb); n < len(cells) {
+			cell := cells[n]
+			cell.wb = r.wb
+			return cell
+		}
+	}
+	cell := blankCell(0)
+	cell.wb = r.wb
+	return cell
+}
+
+// source returns the row and the column holding the value of the Nth column, which is the first cell
+// of the merged range covering it if Options.FillMergedCells is set
+func (r *Row) source(i int) (*Row, int) {
+	if r.sheet == nil || !r.wb.opts.FillMergedCells {
+		return r, i
+	}
+	for _, m := range r.sheet.merged {
+		if r.info.Index < m.FirstRowB || r.info.Index > m.LastRowB || i < int(m.FristColB) || i > int(m.LastColB) {
+			continue
+		}
+		if first := r.sheet.Row(int(m.FirstRowB)); first != nil {
+			return first, int(m.FristColB)
+		}
+		break
+	}
+	return r, i
+}
+
+// content finds the content covering the Nth column and the position of the column in it
+func (r *Row) content(i int) (contentHandler, int) {
 	serial := uint16(i)
 	if ch, ok := r.cols[serial]; ok {
-		strs := ch.String(r.wb)
-		return strs[0]
+		return ch, 0
 	}
 
 	for _, v := range r.cols {
 		if v.FirstCol() <= serial && v.LastCol() >= serial {
-			strs := v.String(r.wb)
-			return strs[serial-v.FirstCol()]
+			return v, int(serial - v.FirstCol())
 		}
 	}
 
-	return ""
+	return nil, 0
 }
 
 //LastCol Get the number of Last Col of the Row.
@@ -45,3 +111,52 @@ func (r *Row) LastCol() int {
 func (r *Row) FirstCol() int {
 	return int(r.info.Fcell)
 }
+
+// Height returns the height of the row in points
+func (r *Row) Height() float64 {
+	return float64(r.info.Height&^rowDefaultHeight) / 20
+}
+
+// CustomHeight reports whether the height of the row was set instead of fitted to its content
+func (r *Row) CustomHeight() bool {
+	return r.info.Flags&rowCustomHeight != 0
+}
+
+// Hidden reports whether the row is hidden
+func (r *Row) Hidden() bool {
+	return r.info.Flags&rowHidden != 0
+}
+
+// OutlineLevel returns the level of the row in the outline, 0 if it is not grouped
+func (r *Row) OutlineLevel() int {
+	return int(r.info.Flags & rowOutlineMask)
+}
+
+// Collapsed reports whether the group of rows following the row is collapsed
+func (r *Row) Collapsed() bool {
+	return r.info.Flags&rowCollapsed != 0
+}
+
+// XF returns the index of the XF record of the empty cells of the row, false if the row has no format
+func (r *Row) XF() (uint16, bool) {
+	if r.info.Flags&rowHasXf == 0 {
+		return 0, false
+	}
+	return uint16(r.info.Flags >> rowXfShift & rowXfMask), true
+}
+
+// DefaultRowHeight returns the height in points of the rows without a ROW record,
+// which includes the empty rows Row returns nil for
+func (w *WorkSheet) DefaultRowHeight() float64 {
+	return float64(w.defaultRow().Height) / 20
+}
+
+// DefaultRowCustomHeight reports whether the default row height was set instead of fitted to the default font
+func (w *WorkSheet) DefaultRowCustomHeight() bool {
+	return w.defRowFlags&defRowCustomHeight != 0
+}
+
+// DefaultRowHidden reports whether the rows without a ROW record are hidden
+func (w *WorkSheet) DefaultRowHidden() bool {
+	return w.defRowFlags&defRowHidden != 0
+}
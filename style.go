@@ -0,0 +1,244 @@
+package xls
+
+import "fmt"
+
+// HorizontalAlign is the horizontal alignment of a cell
+type HorizontalAlign byte
+
+// the horizontal alignments
+const (
+	AlignGeneral HorizontalAlign = iota
+	AlignLeft
+	AlignCenter
+	AlignRight
+	AlignFill
+	AlignJustify
+	AlignCenterAcrossSelection
+	AlignDistributed
+)
+
+var horizontalAlignNames = [...]string{
+	AlignGeneral:               "general",
+	AlignLeft:                  "left",
+	AlignCenter:                "center",
+	AlignRight:                 "right",
+	AlignFill:                  "fill",
+	AlignJustify:               "justify",
+	AlignCenterAcrossSelection: "centerAcrossSelection",
+	AlignDistributed:           "distributed",
+}
+
+func (a HorizontalAlign) String() string {
+	if int(a) < len(horizontalAlignNames) {
+		return horizontalAlignNames[a]
+	}
+	return fmt.Sprintf("HorizontalAlign(%d)", byte(a))
+}
+
+// VerticalAlign is the vertical alignment of a cell
+type VerticalAlign byte
+
+// the vertical alignments
+const (
+	AlignTop VerticalAlign = iota
+	AlignMiddle
+	AlignBottom
+	AlignVerticalJustify
+	AlignVerticalDistributed
+)
+
+var verticalAlignNames = [...]string{
+	AlignTop:                 "top",
+	AlignMiddle:              "center",
+	AlignBottom:              "bottom",
+	AlignVerticalJustify:     "justify",
+	AlignVerticalDistributed: "distributed",
+}
+
+func (a VerticalAlign) String() string {
+	if int(a) < len(verticalAlignNames) {
+		return verticalAlignNames[a]
+	}
+	return fmt.Sprintf("VerticalAlign(%d)", byte(a))
+}
+
+// BorderStyle is the line style of a cell border
+type BorderStyle byte
+
+// the border line styles
+const (
+	BorderNone BorderStyle = iota
+	BorderThin
+	BorderMedium
+	BorderDashed
+	BorderDotted
+	BorderThick
+	BorderDouble
+	BorderHair
+	BorderMediumDashed
+	BorderDashDot
+	BorderMediumDashDot
+	BorderDashDotDot
+	BorderMediumDashDotDot
+	BorderSlantDashDot
+)
+
+var borderStyleNames = [...]string{
+	BorderNone:             "none",
+	BorderThin:             "thin",
+	BorderMedium:           "medium",
+	BorderDashed:           "dashed",
+	BorderDotted:           "dotted",
+	BorderThick:            "thick",
+	BorderDouble:           "double",
+	BorderHair:             "hair",
+	BorderMediumDashed:     "mediumDashed",
+	BorderDashDot:          "dashDot",
+	BorderMediumDashDot:    "mediumDashDot",
+	BorderDashDotDot:       "dashDotDot",
+	BorderMediumDashDotDot: "mediumDashDotDot",
+	BorderSlantDashDot:     "slantDashDot",
+}
+
+func (s BorderStyle) String() string {
+	if int(s) < len(borderStyleNames) {
+		return borderStyleNames[s]
+	}
+	return fmt.Sprintf("BorderStyle(%d)", byte(s))
+}
+
+// Border is one border line of a cell
+type Border struct {
+	Style BorderStyle
+	// Color is the index of the color in the palette
+	Color uint16
+}
+
+// FillPattern is the pattern of a cell background, 0 is no fill, 1 is solid,
+// 2 to 18 are the patterns of Excel's format dialog in their order
+type FillPattern byte
+
+// the fill patterns without a texture
+const (
+	FillNone  FillPattern = 0
+	FillSolid FillPattern = 1
+)
+
+// Style is the formatting of a cell, decoded from its XF record
+type Style struct {
+	// FontIndex is the index of the font, see WorkBook.Font
+	FontIndex uint16
+	// FormatIndex is the index of the number format
+	FormatIndex uint16
+
+	HorizontalAlign HorizontalAlign
+	VerticalAlign   VerticalAlign
+	Wrap            bool
+	ShrinkToFit     bool
+	// Rotation is the angle of the text in degrees, from -90 to 90 with positive values counterclockwise
+	Rotation int
+	// Stacked is set for vertical text with the letters below each other
+	Stacked bool
+	// Indent is the indent level of the text, one level is three spaces wide
+	Indent int
+
+	Left, Right, Top, Bottom Border
+
+	Fill FillPattern
+	// ForegroundColor and BackgroundColor are the palette indexes of the colors of the fill pattern,
+	// a solid fill has the foreground color
+	ForegroundColor uint16
+	BackgroundColor uint16
+
+	Locked bool
+	Hidden bool
+	// IsStyle is set for the XF of a named style, which has no parent
+	IsStyle bool
+	// Parent is the index of the style XF a cell XF inherits from
+	Parent uint16
+}
+
+// the bits of the type field of an XF record
+const (
+	xfLocked      = 0x0001
+	xfHidden      = 0x0002
+	xfStyle       = 0x0004
+	xfParentShift = 4
+)
+
+// the rotation value of stacked text
+const xfStacked = 0xFF
+
+func (x *Xf5) style() Style {
+	s := Style{
+		FontIndex:       x.Font,
+		FormatIndex:     x.Format,
+		HorizontalAlign: HorizontalAlign(x.Align & 0x07),
+		Wrap:            x.Align&0x08 != 0,
+		VerticalAlign:   VerticalAlign(x.Align >> 4 & 0x07),
+		Fill:            FillPattern(x.Fill & 0x3F),
+		ForegroundColor: x.Color & 0x7F,
+		BackgroundColor: x.Color >> 7 & 0x7F,
+		Top:             Border{BorderStyle(x.Border & 0x07), x.Border >> 9 & 0x7F},
+		Left:            Border{BorderStyle(x.Border >> 3 & 0x07), x.Linestyle & 0x7F},
+		Right:           Border{BorderStyle(x.Border >> 6 & 0x07), x.Linestyle >> 7 & 0x7F},
+		Bottom:          Border{BorderStyle(x.Fill >> 6 & 0x07), x.Fill >> 9 & 0x7F},
+	}
+	switch x.Align >> 8 & 0x03 {
+	case 1:
+		s.Stacked = true
+	case 2:
+		s.Rotation = 90
+	case 3:
+		s.Rotation = -90
+	}
+	s.setType(x.Type)
+	return s
+}
+
+func (x *Xf8) style() Style {
+	s := Style{
+		FontIndex:       x.Font,
+		FormatIndex:     x.Format,
+		HorizontalAlign: HorizontalAlign(x.Align & 0x07),
+		Wrap:            x.Align&0x08 != 0,
+		VerticalAlign:   VerticalAlign(x.Align >> 4 & 0x07),
+		Indent:          int(x.Ident & 0x0F),
+		ShrinkToFit:     x.Ident&0x10 != 0,
+		Left:            Border{BorderStyle(x.Linestyle & 0x0F), uint16(x.Linestyle >> 16 & 0x7F)},
+		Right:           Border{BorderStyle(x.Linestyle >> 4 & 0x0F), uint16(x.Linestyle >> 23 & 0x7F)},
+		Top:             Border{BorderStyle(x.Linestyle >> 8 & 0x0F), uint16(x.Linecolor & 0x7F)},
+		Bottom:          Border{BorderStyle(x.Linestyle >> 12 & 0x0F), uint16(x.Linecolor >> 7 & 0x7F)},
+		Fill:            FillPattern(x.Linecolor >> 26 & 0x3F),
+		ForegroundColor: x.Groundcolor & 0x7F,
+		BackgroundColor: x.Groundcolor >> 7 & 0x7F,
+	}
+	switch {
+	case x.Rotation == xfStacked:
+		s.Stacked = true
+	case x.Rotation > 90 && x.Rotation <= 180:
+		s.Rotation = 90 - int(x.Rotation)
+	case x.Rotation <= 90:
+		s.Rotation = int(x.Rotation)
+	}
+	s.setType(x.Type)
+	return s
+}
+
+// setType decodes the protection and the parent of the type field
+func (s *Style) setType(typ uint16) {
+	s.Locked = typ&xfLocked != 0
+	s.Hidden = typ&xfHidden != 0
+	s.IsStyle = typ&xfStyle != 0
+	if !s.IsStyle {
+		s.Parent = typ >> xfParentShift
+	}
+}
+
+// Style returns the formatting of the XF record with the index, false if there is no such record
+func (w *WorkBook) Style(xf uint16) (Style, bool) {
+	if int(xf) >= len(w.Xfs) {
+		return Style{}, false
+	}
+	return w.Xfs[xf].style(), true
+}
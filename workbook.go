@@ -1,13 +1,16 @@
 package xls
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
-	"os"
+	"sync"
 	"unicode/utf16"
 
-	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding"
 )
 
 // WorkBook contains an Excel workbook
@@ -19,24 +22,44 @@ type WorkBook struct {
 	Fonts    []Font
 	Formats  map[uint16]*Format
 	//All the sheets from the workbook
-	sheets        []*WorkSheet
-	Author        string
-	rs            io.ReadSeeker
-	sst           []string
+	sheets   []*WorkSheet
+	Author   string
+	Metadata Metadata
+	// the workbook stream, read in place from the file, sheets are read through section readers
+	// so they can be loaded concurrently
+	rs  *io.SectionReader
+	sst []string
+	// the formatting runs and phonetic blocks of the shared strings that have them
+	sstRich       map[int]*richData
+	sstCount      uint32
 	continueUTF16 uint16
 	continueRich  uint16
 	continueAPSB  uint32
 	dateMode      uint16
+	// the colors of the PALETTE record, replacing the default palette from the index 8 on
+	palette []RGB
+	// the names and the external references of formulas
+	names        []string
+	externSheets []xti
+	supBooks     []*supBook
+	// the encoding of byte strings chosen by the caller, overrides Codepage
+	charset encoding.Encoding
+	opts    Options
+	// set for the workbooks of NewWorkBook, which can be changed and saved
+	writable bool
+	sstIndex map[string]uint32
 }
 
-//read workbook from ole2 file
-func newWorkBookFromOle2(rs io.ReadSeeker) (*WorkBook, error) {
+//read workbook from the workbook stream of the ole2 file
+func newWorkBookFromOle2(ctx context.Context, rs *io.SectionReader, charset encoding.Encoding, opts Options) (*WorkBook, error) {
 	wb := &WorkBook{
 		Formats: make(map[uint16]*Format),
 		rs:      rs,
 		sheets:  make([]*WorkSheet, 0),
+		charset: charset,
+		opts:    opts,
 	}
-	if err := wb.Parse(rs); err != nil {
+	if err := wb.parse(ctx, wb.section(0)); err != nil {
 		return nil, err
 	}
 	return wb, nil
@@ -44,16 +67,33 @@ func newWorkBookFromOle2(rs io.ReadSeeker) (*WorkBook, error) {
 
 // Parse parses the given reader into the workbook
 func (w *WorkBook) Parse(buf io.Reader) error {
+	return w.parse(context.Background(), buf)
+}
+
+func (w *WorkBook) parse(ctx context.Context, buf io.Reader) error {
 	b := new(bof)
 	preBof := new(bof)
 	offset := 0
+	var pos, records int64
 	for {
+		if records++; records%ctxCheckInterval == 1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := w.opts.checkRecords(records); err != nil {
+			return &ParseError{Offset: pos, Err: err}
+		}
 		if err := binary.Read(buf, binary.LittleEndian, b); err == nil {
+			id, size := b.ID, b.Size
 			preBof, b, offset, err = w.parseBof(buf, b, preBof, offset)
 			if err != nil {
-				return err
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return &ParseError{RecordID: id, Offset: pos, Err: err}
 			}
-
+			pos += 4 + int64(size)
 		} else {
 			break
 		}
@@ -61,6 +101,26 @@ func (w *WorkBook) Parse(buf io.Reader) error {
 	return nil
 }
 
+// addSst appends str to the shared string i, which is either the last or the next one,
+// with the formatting runs and the phonetic block read with it
+func (w *WorkBook) addSst(i int, str string, rich *richData) {
+	for len(w.sst) <= i {
+		w.sst = append(w.sst, "")
+	}
+	w.sst[i] += str
+	if rich != nil {
+		if w.sstRich == nil {
+			w.sstRich = make(map[int]*richData)
+		}
+		if r := w.sstRich[i]; r != nil {
+			r.runs = append(r.runs, rich.runs...)
+			r.phonetic = append(r.phonetic, rich.phonetic...)
+		} else {
+			w.sstRich[i] = rich
+		}
+	}
+}
+
 func (w *WorkBook) addXf(xf stXfData) {
 	w.Xfs = append(w.Xfs, xf)
 }
@@ -72,8 +132,9 @@ func (w *WorkBook) addFont(font *FontInfo, buf io.Reader) {
 
 func (w *WorkBook) addFormat(format *Format) {
 	if w.Formats == nil {
-		os.Exit(1)
+		w.Formats = make(map[uint16]*Format)
 	}
+	format.code = parseNumFormat(format.str)
 	w.Formats[format.Head.Index] = format
 }
 
@@ -112,11 +173,12 @@ func (w *WorkBook) parseBof(buf io.Reader, b *bof, pre *bof, preOffset int) (aft
 					return nil, nil, 0, err
 				}
 			}
-			for err == nil && preOffset < len(w.sst) {
+			for err == nil && int64(preOffset) < int64(w.sstCount) {
 				var str string
 				if size > 0 {
-					str, err = w.getString(bufItem, size)
-					w.sst[preOffset] = w.sst[preOffset] + str
+					var rich *richData
+					str, rich, err = w.getRichString(bufItem, size)
+					w.addSst(preOffset, str, rich)
 				}
 
 				if err == io.EOF {
@@ -126,7 +188,10 @@ func (w *WorkBook) parseBof(buf io.Reader, b *bof, pre *bof, preOffset int) (aft
 				}
 
 				preOffset++
-				if err := binary.Read(bufItem, binary.LittleEndian, &size); err != nil {
+				// the record may end with a whole string
+				if err := binary.Read(bufItem, binary.LittleEndian, &size); err == io.EOF {
+					break
+				} else if err != nil {
 					return nil, nil, 0, err
 				}
 			}
@@ -139,15 +204,21 @@ func (w *WorkBook) parseBof(buf io.Reader, b *bof, pre *bof, preOffset int) (aft
 		if err := binary.Read(bufItem, binary.LittleEndian, info); err != nil {
 			return nil, nil, 0, err
 		}
-		w.sst = make([]string, info.Count)
+		if err := w.opts.checkSST(info.Count); err != nil {
+			return nil, nil, 0, err
+		}
+		w.sst = nil
+		w.sstRich = nil
+		w.sstCount = info.Count
 		var size uint16
 		var i = 0
-		for ; i < int(info.Count); i++ {
+		for ; int64(i) < int64(info.Count); i++ {
 			var err error
 			if err = binary.Read(bufItem, binary.LittleEndian, &size); err == nil {
 				var str string
-				str, err = w.getString(bufItem, size)
-				w.sst[i] = w.sst[i] + str
+				var rich *richData
+				str, rich, err = w.getRichString(bufItem, size)
+				w.addSst(i, str, rich)
 			}
 
 			if err == io.EOF {
@@ -198,19 +269,143 @@ func (w *WorkBook) parseBof(buf io.Reader, b *bof, pre *bof, preOffset int) (aft
 		if err := binary.Read(bufItem, binary.LittleEndian, &w.dateMode); err != nil {
 			return nil, nil, 0, err
 		}
+	case 0x92: // PALETTE
+		var count uint16
+		if err := binary.Read(bufItem, binary.LittleEndian, &count); err != nil {
+			return nil, nil, 0, err
+		}
+		w.palette = nil
+		for i := uint16(0); i < count; i++ {
+			var c [4]byte
+			if err := binary.Read(bufItem, binary.LittleEndian, &c); err != nil {
+				return nil, nil, 0, err
+			}
+			w.palette = append(w.palette, RGB{c[0], c[1], c[2]})
+		}
+	// the records of the names and external references are only used to show formulas, a broken one is
+	// kept as a placeholder so the indexes of the others stay right, and the formulas using it fail
+	case 0x18: // NAME
+		name, _ := w.readName(bufItem)
+		w.names = append(w.names, name)
+	case 0x17: // EXTERNSHEET
+		if w.Is5ver {
+			break
+		}
+		var count uint16
+		if err := binary.Read(bufItem, binary.LittleEndian, &count); err != nil {
+			break
+		}
+		for i := uint16(0); i < count; i++ {
+			var x xti
+			if err := binary.Read(bufItem, binary.LittleEndian, &x); err != nil {
+				break
+			}
+			w.externSheets = append(w.externSheets, x)
+		}
+	case 0x1AE: // SUPBOOK
+		book, err := w.readSupBook(bufItem)
+		if err != nil {
+			book = &supBook{broken: true}
+		}
+		w.supBooks = append(w.supBooks, book)
+	case 0x23: // EXTERNNAME
+		if w.Is5ver || len(w.supBooks) == 0 {
+			break
+		}
+		name, _ := w.readExternName(bufItem)
+		book := w.supBooks[len(w.supBooks)-1]
+		book.names = append(book.names, name)
 	}
 	return
 }
-func decodeWindows1251(enc []byte) string {
-	dec := charmap.Windows1251.NewDecoder()
-	out, _ := dec.Bytes(enc)
-	return string(out)
+
+// readName reads the name of a NAME record, the built-in names are stored as a single character
+func (w *WorkBook) readName(buf io.Reader) (string, error) {
+	var head struct {
+		Flags   uint16
+		Key     byte
+		Size    byte
+		Formula uint16
+		_       uint16
+		Sheet   uint16
+		_       [4]byte
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &head); err != nil {
+		return "", err
+	}
+	name, err := w.recordString(buf, uint16(head.Size))
+	if err != nil {
+		return "", err
+	}
+	if code := []rune(name); head.Flags&0x20 != 0 && len(code) == 1 && int(code[0]) < len(builtinNames) {
+		name = builtinNames[code[0]]
+	}
+	return name, nil
 }
+
+// readSupBook reads the workbook of a SUPBOOK record with the names of its sheets
+func (w *WorkBook) readSupBook(buf io.Reader) (*supBook, error) {
+	var head struct {
+		Sheets uint16
+		Size   uint16
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &head); err != nil {
+		return nil, err
+	}
+	book := new(supBook)
+	switch head.Size {
+	case 0x0401:
+		book.self = true
+	case 0x3A01:
+		book.addin = true
+	default:
+		path, err := w.recordString(buf, head.Size)
+		if err != nil {
+			return nil, err
+		}
+		book.file = externFileName(path)
+		for i := uint16(0); i < head.Sheets; i++ {
+			var size uint16
+			if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+				return nil, err
+			}
+			name, err := w.recordString(buf, size)
+			if err != nil {
+				return nil, err
+			}
+			book.sheets = append(book.sheets, name)
+		}
+	}
+	return book, nil
+}
+
+// readExternName reads the name of an EXTERNNAME record
+func (w *WorkBook) readExternName(buf io.Reader) (string, error) {
+	var head struct {
+		Flags uint16
+		_     uint32
+		Size  byte
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &head); err != nil {
+		return "", err
+	}
+	return w.recordString(buf, uint16(head.Size))
+}
+
 func (w *WorkBook) getString(buf io.Reader, size uint16) (res string, err error) {
+	res, _, err = w.getRichString(buf, size)
+	return
+}
+
+// getRichString reads a string like getString, with the formatting runs and the phonetic block of BIFF8 strings
+func (w *WorkBook) getRichString(buf io.Reader, size uint16) (res string, rich *richData, err error) {
+	if err := w.opts.checkString(size); err != nil {
+		return "", nil, err
+	}
 	if w.Is5ver {
 		var bts = make([]byte, size)
 		_, err = buf.Read(bts)
-		res = decodeWindows1251(bts)
+		res = w.decode(bts)
 	} else {
 		var richtextNum = uint16(0)
 		var phoneticSize = uint32(0)
@@ -231,13 +426,15 @@ func (w *WorkBook) getString(buf io.Reader, size uint16) (res string, err error)
 		if flag&0x1 != 0 {
 			var bts = make([]uint16, size)
 			var i = uint16(0)
-			for ; i < size && err == nil; i++ {
-				err = binary.Read(buf, binary.LittleEndian, &bts[i])
+			for ; i < size; i++ {
+				if err = binary.Read(buf, binary.LittleEndian, &bts[i]); err != nil {
+					break
+				}
 			}
 			runes := utf16.Decode(bts[:i])
 			res = string(runes)
 			if i < size {
-				w.continueUTF16 = size - i + 1
+				w.continueUTF16 = size - i
 			}
 		} else {
 			var bts = make([]byte, size)
@@ -267,40 +464,149 @@ func (w *WorkBook) getString(buf io.Reader, size uint16) (res string, err error)
 			err = binary.Read(buf, binary.LittleEndian, bts)
 			if err == io.EOF {
 				w.continueRich = richtextNum
+			} else if err == nil {
+				rich = &richData{runs: bts}
 			}
 		}
 		if phoneticSize > 0 {
-			bts := make([]byte, phoneticSize)
-			err = binary.Read(buf, binary.LittleEndian, bts)
+			var phonetic bytes.Buffer
+			var n int64
+			n, err = io.CopyN(&phonetic, buf, int64(phoneticSize))
 			if err == io.EOF {
-				w.continueAPSB = phoneticSize
+				w.continueAPSB = phoneticSize - uint32(n)
+			}
+			if n > 0 {
+				if rich == nil {
+					rich = new(richData)
+				}
+				rich.phonetic = phonetic.Bytes()
 			}
 		}
 	}
 	return
 }
 
+// recordString reads a string that does not continue in the next record,
+// without the continuation state of the shared strings
+func (w *WorkBook) recordString(buf io.Reader, size uint16) (string, error) {
+	wb := WorkBook{Is5ver: w.Is5ver, Codepage: w.Codepage, charset: w.charset, opts: w.opts}
+	return wb.getString(buf, size)
+}
+
 func (w *WorkBook) addSheet(sheet *boundsheet, buf io.Reader) {
 	name, _ := w.getString(buf, uint16(sheet.Name))
 	w.sheets = append(w.sheets, &WorkSheet{bs: sheet, Name: name, wb: w})
 }
 
 //reading a sheet from the compress file to memory, you should call this before you try to get anything from sheet
-func (w *WorkBook) prepareSheet(sheet *WorkSheet) {
-	w.rs.Seek(int64(sheet.bs.Filepos), 0)
-	sheet.parse(w.rs)
+func (w *WorkBook) prepareSheet(ctx context.Context, sheet *WorkSheet) error {
+	offset := int64(sheet.bs.Filepos)
+	sheet.err = sheet.parse(ctx, w.section(offset), offset)
+	if sheet.err == ctx.Err() && sheet.err != nil {
+		// a canceled parse is tried again by the next call
+		sheet.parsed = false
+	}
+	return sheet.err
+}
+
+// section returns a buffered reader of the workbook stream from the offset, independent of other readers
+func (w *WorkBook) section(offset int64) io.Reader {
+	if w.rs == nil {
+		return bytes.NewReader(nil)
+	}
+	return bufio.NewReader(io.NewSectionReader(w.rs, offset, w.rs.Size()-offset))
+}
+
+// loadSheet parses the sheet once, it is safe to call from several goroutines
+func (w *WorkBook) loadSheet(ctx context.Context, sheet *WorkSheet) error {
+	sheet.mu.Lock()
+	defer sheet.mu.Unlock()
+	if !sheet.parsed {
+		w.prepareSheet(ctx, sheet)
+	}
+	return sheet.err
+}
+
+// LoadAllSheets parses all sheets of the workbook in parallel, with at most concurrency goroutines,
+// or one per sheet if concurrency is below 1. It returns the first error of a sheet, or the error of ctx
+// if it is done before all sheets are started. GetSheet returns the loaded sheets without parsing again.
+func (w *WorkBook) LoadAllSheets(ctx context.Context, concurrency int) error {
+	if concurrency < 1 || concurrency > len(w.sheets) {
+		concurrency = len(w.sheets)
+	}
+	jobs := make(chan *WorkSheet)
+	errs := make(chan error, len(w.sheets))
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sheet := range jobs {
+				if err := w.loadSheet(ctx, sheet); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	var err error
+feed:
+	for _, sheet := range w.sheets {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- sheet:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if sheetErr, ok := <-errs; ok {
+		return sheetErr
+	}
+	return err
 }
 
-// GetSheet gets one sheet by its number
+// GetSheet gets one sheet by its number, it returns nil if there is no such sheet.
+// A sheet that can not be read completely is returned with the rows read before the error,
+// use GetSheetE to get the error.
 func (w *WorkBook) GetSheet(num int) *WorkSheet {
-	if num >= len(w.sheets) {
-		return nil
+	s, _ := w.GetSheetE(num)
+	return s
+}
+
+// GetSheetE gets one sheet by its number and returns the error of reading it,
+// which is a *ParseError if a record of the sheet is broken
+func (w *WorkBook) GetSheetE(num int) (*WorkSheet, error) {
+	return w.GetSheetContext(context.Background(), num)
+}
+
+// GetSheetContext is GetSheetE stopping when ctx is done,
+// a sheet whose parsing was stopped is parsed again by the next call
+func (w *WorkBook) GetSheetContext(ctx context.Context, num int) (*WorkSheet, error) {
+	if num < 0 || num >= len(w.sheets) {
+		return nil, fmt.Errorf("xls: no sheet %d in the workbook of %d sheets", num, len(w.sheets))
 	}
 	s := w.sheets[num]
-	if !s.parsed {
-		w.prepareSheet(s)
+	return s, w.loadSheet(ctx, s)
+}
+
+// VisibleSheets loads and returns the worksheets that are not hidden, skipping charts and macro sheets
+func (w *WorkBook) VisibleSheets() ([]*WorkSheet, error) {
+	var res []*WorkSheet
+	for _, sheet := range w.sheets {
+		if sheet.Visibility() != SheetVisible || sheet.Type() != SheetWorksheet {
+			continue
+		}
+		if err := w.loadSheet(context.Background(), sheet); err != nil {
+			return nil, err
+		}
+		res = append(res, sheet)
 	}
-	return s
+	return res, nil
 }
 
 // NumSheets gets the number of all sheets
@@ -316,7 +622,7 @@ func (w *WorkBook) ReadAllCells(max int) (res [][]string) {
 	for _, sheet := range w.sheets {
 		if len(res) < max {
 			max = max - len(res)
-			w.prepareSheet(sheet)
+			w.loadSheet(context.Background(), sheet)
 			if sheet.MaxRow != 0 {
 				leng := int(sheet.MaxRow) + 1
 				if max < leng {
@@ -324,21 +630,22 @@ func (w *WorkBook) ReadAllCells(max int) (res [][]string) {
 				}
 				temp := make([][]string, leng)
 				for k, row := range sheet.rows {
+					if int(k) >= leng {
+						continue
+					}
 					data := make([]string, 0)
 					if len(row.cols) > 0 {
 						for _, col := range row.cols {
-							if uint16(len(data)) <= col.LastCol() {
-								data = append(data, make([]string, col.LastCol()-uint16(len(data))+1)...)
+							if len(data) <= int(col.LastCol()) {
+								data = append(data, make([]string, int(col.LastCol())-len(data)+1)...)
 							}
 							str := col.String(w)
 
-							for i := uint16(0); i < col.LastCol()-col.FirstCol()+1; i++ {
-								data[col.FirstCol()+i] = str[i]
+							for i := 0; i < len(str) && int(col.FirstCol())+i < len(data); i++ {
+								data[int(col.FirstCol())+i] = str[i]
 							}
 						}
-						if leng > int(k) {
-							temp[k] = data
-						}
+						temp[k] = data
 					}
 				}
 				res = append(res, temp...)
@@ -1,19 +1,70 @@
 package xls
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"unicode/utf16"
+	"sync"
 )
 
 type boundsheet struct {
 	Filepos uint32
-	Type    byte
 	Visible byte
+	Type    byte
 	Name    byte
 }
 
+// SheetVisibility tells whether a sheet is shown in the tabs of the workbook
+type SheetVisibility byte
+
+// the visibilities of sheets
+const (
+	SheetVisible SheetVisibility = iota
+	SheetHidden
+	// SheetVeryHidden sheets can only be shown again by a macro
+	SheetVeryHidden
+)
+
+var sheetVisibilityNames = [...]string{
+	SheetVisible:    "visible",
+	SheetHidden:     "hidden",
+	SheetVeryHidden: "very hidden",
+}
+
+func (v SheetVisibility) String() string {
+	if int(v) < len(sheetVisibilityNames) {
+		return sheetVisibilityNames[v]
+	}
+	return fmt.Sprintf("SheetVisibility(%d)", byte(v))
+}
+
+// SheetType is the kind of a sheet
+type SheetType byte
+
+// the kinds of sheets
+const (
+	SheetWorksheet SheetType = 0x00
+	SheetMacro     SheetType = 0x01
+	SheetChart     SheetType = 0x02
+	SheetVBModule  SheetType = 0x06
+)
+
+var sheetTypeNames = map[SheetType]string{
+	SheetWorksheet: "worksheet",
+	SheetMacro:     "macro sheet",
+	SheetChart:     "chart",
+	SheetVBModule:  "VB module",
+}
+
+func (t SheetType) String() string {
+	if name, ok := sheetTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("SheetType(%d)", byte(t))
+}
+
 //WorkSheet in one WorkBook
 type WorkSheet struct {
 	bs   *boundsheet
@@ -23,37 +74,91 @@ type WorkSheet struct {
 	//NOTICE: this is the max row number of the sheet, so it should be count -1
 	MaxRow uint16
 	parsed bool
+	// guards the parsing of the sheet
+	mu sync.Mutex
+	// the number of cells read, checked against Options.MaxCellsPerSheet
+	cells int64
+	// the error of parsing the sheet
+	err error
+	// the formula waiting for its string result in a following STRING record
+	strFormula *FormulaCol
+	// the last formula, which a following SHRFMLA or ARRAY record may belong to
+	lastFormula *FormulaCol
+	// the shared and array formulas by their first cell
+	formulas map[[2]uint16]*sharedFormula
+	merged   []CellRange
+	// the range of the DIMENSIONS record, nil if it is missing or empty
+	dimensions *CellRange
+	// the COLINFO records, the DEFCOLWIDTH width in characters and the STANDARDWIDTH width in 1/256 characters,
+	// the widths are 0 if their record is missing
+	columns       []colInfo
+	defColWidth   uint16
+	standardWidth uint16
+	// the DEFAULTROWHEIGHT record of the rows without a ROW record, the height is 0 if it is missing
+	defRowFlags  uint16
+	defRowHeight uint16
 }
 
 // Row returns the row at the specified index
 func (w *WorkSheet) Row(i int) *Row {
-	row := w.rows[uint16(i)]
-	if row != nil {
-		row.wb = w.wb
-	}
-	return row
+	return w.rows[uint16(i)]
 }
 
-func (w *WorkSheet) parse(buf io.ReadSeeker) error {
+// parse reads the records of the sheet from buf, which is positioned at the given offset of the stream
+func (w *WorkSheet) parse(ctx context.Context, buf io.Reader, offset int64) error {
 	w.rows = make(map[uint16]*Row)
-	b := new(bof)
+	w.parsed = true
+	w.cells = 0
 	var preBof *bof
-	for {
-		if err := binary.Read(buf, binary.LittleEndian, b); err == nil {
-			preBof, err = w.parseBof(buf, b, preBof)
-			if err != nil {
+	for records := int64(1); ; records++ {
+		if records%ctxCheckInterval == 1 {
+			if err := ctx.Err(); err != nil {
 				return err
 			}
-			if b.ID == 0xa {
-				break
-			}
-		} else {
-			fmt.Println(err)
-			break
 		}
+		if err := w.wb.opts.checkRecords(records); err != nil {
+			return &ParseError{Offset: offset, Err: err}
+		}
+		b, bts, err := readRecord(buf, offset)
+		if err != nil {
+			return err
+		}
+		if preBof, err = w.parseBof(bytes.NewReader(bts), b, preBof); err != nil {
+			return &ParseError{RecordID: b.ID, Offset: offset, Err: err}
+		}
+		if err := w.wb.opts.checkCells(w.cells); err != nil {
+			return &ParseError{RecordID: b.ID, Offset: offset, Err: err}
+		}
+		if b.ID == 0xa {
+			return nil
+		}
+		offset += 4 + int64(b.Size)
 	}
-	w.parsed = true
-	return nil
+}
+
+// getString reads a string of a sheet record, without the continuation state of the workbook
+// as sheet strings never continue in the next record and sheets are parsed concurrently
+func (w *WorkSheet) getString(buf io.Reader, size uint16) (string, error) {
+	return w.wb.recordString(buf, size)
+}
+
+// readRecord reads the header and the body of the record at the given offset of the stream
+func readRecord(buf io.Reader, offset int64) (*bof, []byte, error) {
+	b := new(bof)
+	if err := binary.Read(buf, binary.LittleEndian, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, &ParseError{Offset: offset, Err: err}
+	}
+	bts := make([]byte, b.Size)
+	if _, err := io.ReadFull(buf, bts); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, &ParseError{RecordID: b.ID, Offset: offset, Err: err}
+	}
+	return b, bts, nil
 }
 
 func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error) {
@@ -67,6 +172,9 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 		}
 		w.addRow(r)
 	case 0x0BD: //MULRK
+		if b.Size < 6 {
+			return nil, errRecordSize
+		}
 		mc := new(MulrkCol)
 		size := (b.Size - 6) / 6
 		if err := binary.Read(buf, binary.LittleEndian, &mc.Col); err != nil {
@@ -74,16 +182,26 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 		}
 		mc.Xfrks = make([]XfRk, size)
 		for i := uint16(0); i < size; i++ {
-			binary.Read(buf, binary.LittleEndian, &mc.Xfrks[i])
+			if err := binary.Read(buf, binary.LittleEndian, &mc.Xfrks[i]); err != nil {
+				return nil, err
+			}
 		}
 		if err := binary.Read(buf, binary.LittleEndian, &mc.LastColB); err != nil {
 			return nil, err
 		}
+		if int(mc.LastColB)-int(mc.FirstColB)+1 != len(mc.Xfrks) {
+			return nil, errColumnRange
+		}
 		col = mc
 	case 0x0BE: //MULBLANK
+		if b.Size < 6 {
+			return nil, errRecordSize
+		}
 		mc := new(MulBlankCol)
 		size := (b.Size - 6) / 2
-		binary.Read(buf, binary.LittleEndian, &mc.Col)
+		if err := binary.Read(buf, binary.LittleEndian, &mc.Col); err != nil {
+			return nil, err
+		}
 		mc.Xfs = make([]uint16, size)
 		for i := uint16(0); i < size; i++ {
 			if err := binary.Read(buf, binary.LittleEndian, &mc.Xfs[i]); err != nil {
@@ -93,6 +211,9 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 		if err := binary.Read(buf, binary.LittleEndian, &mc.LastColB); err != nil {
 			return nil, err
 		}
+		if int(mc.LastColB)-int(mc.FirstColB)+1 != len(mc.Xfs) {
+			return nil, errColumnRange
+		}
 		col = mc
 	case 0x203: //NUMBER
 		col = new(NumberCol)
@@ -100,6 +221,9 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 			return nil, err
 		}
 	case 0x06: //FORMULA
+		if b.Size < 20 {
+			return nil, errRecordSize
+		}
 		c := new(FormulaCol)
 		if err := binary.Read(buf, binary.LittleEndian, &c.Header); err != nil {
 			return nil, err
@@ -108,17 +232,126 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 		if err := binary.Read(buf, binary.LittleEndian, &c.Bts); err != nil {
 			return nil, err
 		}
+		if !c.isNumber() && c.Header.Result[0] == formulaResultString {
+			w.strFormula = c
+		}
+		if first, ok := c.expCell(); ok {
+			c.shared = w.formulas[first]
+		}
+		w.lastFormula = c
 		col = c
+	case 0x200: //DIMENSIONS
+		// the last row and column are one past the used range
+		var rows [2]uint32
+		var cols [2]uint16
+		if w.wb.Is5ver {
+			var rows5 [2]uint16
+			if err := binary.Read(buf, binary.LittleEndian, &rows5); err != nil {
+				return nil, err
+			}
+			rows = [2]uint32{uint32(rows5[0]), uint32(rows5[1])}
+		} else if err := binary.Read(buf, binary.LittleEndian, &rows); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(buf, binary.LittleEndian, &cols); err != nil {
+			return nil, err
+		}
+		w.dimensions = nil
+		if rows[0] < rows[1] && rows[1] <= 0x10000 && cols[0] < cols[1] && cols[1] <= 0x100 {
+			w.dimensions = &CellRange{uint16(rows[0]), uint16(rows[1] - 1), cols[0], cols[1] - 1}
+		}
+	case 0xE5: //MERGEDCELLS
+		// the merged ranges are only layout, a short record ends the list and a broken range is skipped
+		var count uint16
+		binary.Read(buf, binary.LittleEndian, &count)
+		for i := uint16(0); i < count; i++ {
+			var r CellRange
+			if err := binary.Read(buf, binary.LittleEndian, &r); err != nil {
+				break
+			}
+			if r.clamp() {
+				w.merged = append(w.merged, r)
+			}
+		}
+	case 0x7D: //COLINFO
+		var c colInfo
+		if err := binary.Read(buf, binary.LittleEndian, &c); err != nil {
+			return nil, err
+		}
+		// a range beyond the last column is clamped, a broken range only loses its formatting
+		if c.LastCol > 0xFF {
+			c.LastCol = 0xFF
+		}
+		if c.FirstCol <= c.LastCol {
+			w.columns = append(w.columns, c)
+		}
+	case 0x55: //DEFCOLWIDTH
+		if err := binary.Read(buf, binary.LittleEndian, &w.defColWidth); err != nil {
+			return nil, err
+		}
+	case 0x99: //STANDARDWIDTH
+		if err := binary.Read(buf, binary.LittleEndian, &w.standardWidth); err != nil {
+			return nil, err
+		}
+	case 0x225: //DEFAULTROWHEIGHT
+		if err := binary.Read(buf, binary.LittleEndian, &w.defRowFlags); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(buf, binary.LittleEndian, &w.defRowHeight); err != nil {
+			return nil, err
+		}
+	case 0x4BC, 0x221: //SHRFMLA, ARRAY
+		var head struct {
+			FirstRow, LastRow uint16
+			FirstCol, LastCol byte
+		}
+		if err := binary.Read(buf, binary.LittleEndian, &head); err != nil {
+			return nil, err
+		}
+		if head.LastRow < head.FirstRow || head.LastCol < head.FirstCol {
+			return nil, errColumnRange
+		}
+		f := &sharedFormula{CellRange: CellRange{head.FirstRow, head.LastRow, uint16(head.FirstCol), uint16(head.LastCol)}}
+		// the options and the use count of SHRFMLA, the options of ARRAY
+		skip := int64(2)
+		if b.ID == 0x221 {
+			f.array = true
+			skip = 6
+		}
+		if _, err := buf.Seek(skip, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		if f.tokens, err = io.ReadAll(buf); err != nil {
+			return nil, err
+		}
+		w.addSharedFormula(f)
+	case 0x207: //STRING
+		var count uint16
+		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		str, err := w.getString(buf, count)
+		if err != nil {
+			return nil, err
+		}
+		if w.strFormula != nil {
+			w.strFormula.str = str
+			w.strFormula = nil
+		}
 	case 0x27e: //RK
 		col = new(RkCol)
 		if err := binary.Read(buf, binary.LittleEndian, col); err != nil {
 			return nil, err
 		}
 	case 0xFD: //LABELSST
-		col = new(LabelsstCol)
-		if err := binary.Read(buf, binary.LittleEndian, col); err != nil {
+		c := new(LabelsstCol)
+		if err := binary.Read(buf, binary.LittleEndian, c); err != nil {
 			return nil, err
 		}
+		if int(c.Sst) >= len(w.wb.sst) {
+			return nil, fmt.Errorf("xls: shared string %d out of %d", c.Sst, len(w.wb.sst))
+		}
+		col = c
 	case 0x204:
 		c := new(labelCol)
 		if err := binary.Read(buf, binary.LittleEndian, &c.BlankCol); err != nil {
@@ -128,113 +361,129 @@ func (w *WorkSheet) parseBof(buf io.ReadSeeker, b *bof, pre *bof) (*bof, error)
 		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
 			return nil, err
 		}
-		c.Str, err = w.wb.getString(buf, count)
+		c.Str, err = w.getString(buf, count)
 		if err != nil {
 			return nil, err
 		}
 		col = c
+	case 0x205: //BOOLERR
+		col = new(BoolErrCol)
+		if err := binary.Read(buf, binary.LittleEndian, col); err != nil {
+			return nil, err
+		}
 	case 0x201: //BLANK
 		col = new(BlankCol)
 		if err := binary.Read(buf, binary.LittleEndian, col); err != nil {
 			return nil, err
 		}
 	case 0x1b8: //HYPERLINK
-		var hy HyperLink
-		if err := binary.Read(buf, binary.LittleEndian, &hy.CellRange); err != nil {
+		hy, err := parseHyperLink(buf, b)
+		if err != nil {
 			return nil, err
 		}
-		buf.Seek(20, 1)
-		var flag uint32
-		if err := binary.Read(buf, binary.LittleEndian, &flag); err != nil {
-			return nil, err
+		if hy != nil {
+			w.addRange(&hy.CellRange, hy)
 		}
-		var count uint32
+	}
+	if col != nil {
+		w.add(col)
+	}
+	return b, nil
+}
 
-		if flag&0x14 != 0 {
+// parseHyperLink decodes the body of a HYPERLINK record, it returns nil for a broken range
+func parseHyperLink(buf io.ReadSeeker, b *bof) (*HyperLink, error) {
+	var err error
+	var hy HyperLink
+	if err := binary.Read(buf, binary.LittleEndian, &hy.CellRange); err != nil {
+		return nil, err
+	}
+	if !hy.CellRange.clamp() {
+		// a hyperlink without cells is skipped
+		return nil, nil
+	}
+	buf.Seek(20, 1)
+	var flag uint32
+	if err := binary.Read(buf, binary.LittleEndian, &flag); err != nil {
+		return nil, err
+	}
+	var count uint32
+
+	if flag&0x14 != 0 {
+		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		hy.Description, err = b.utf16String(buf, count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flag&0x80 != 0 {
+		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		hy.TargetFrame, err = b.utf16String(buf, count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flag&0x1 != 0 {
+		var guid [2]uint64
+		if err := binary.Read(buf, binary.BigEndian, &guid); err != nil {
+			return nil, err
+		}
+		if guid[0] == 0xE0C9EA79F9BACE11 && guid[1] == 0x8C8200AA004BA90B { //URL
+			hy.IsURL = true
 			if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
 				return nil, err
 			}
-			hy.Description, err = b.utf16String(buf, count)
+			hy.URL, err = b.utf16String(buf, count/2)
 			if err != nil {
 				return nil, err
 			}
-		}
-		if flag&0x80 != 0 {
+		} else if guid[0] == 0x303000000000000 && guid[1] == 0xC000000000000046 { //URL{
+			var upCount uint16
+			if err := binary.Read(buf, binary.LittleEndian, &upCount); err != nil {
+				return nil, err
+			}
 			if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
 				return nil, err
 			}
-			hy.TargetFrame, err = b.utf16String(buf, count)
-			if err != nil {
+			if int64(count) > remaining(buf) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			bts := make([]byte, count)
+			if err := binary.Read(buf, binary.LittleEndian, &bts); err != nil {
 				return nil, err
 			}
-		}
-		if flag&0x1 != 0 {
-			var guid [2]uint64
-			if err := binary.Read(buf, binary.BigEndian, &guid); err != nil {
+			hy.ShortedFilePath = string(bts)
+			buf.Seek(24, 1)
+			if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
 				return nil, err
 			}
-			if guid[0] == 0xE0C9EA79F9BACE11 && guid[1] == 0x8C8200AA004BA90B { //URL
-				hy.IsURL = true
+			if count > 0 {
 				if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
 					return nil, err
 				}
-				hy.URL, err = b.utf16String(buf, count/2)
+				buf.Seek(2, 1)
+				hy.ExtendedFilePath, err = b.utf16String(buf, count/2+1)
 				if err != nil {
 					return nil, err
 				}
-			} else if guid[0] == 0x303000000000000 && guid[1] == 0xC000000000000046 { //URL{
-				var upCount uint16
-				if err := binary.Read(buf, binary.LittleEndian, &upCount); err != nil {
-					return nil, err
-				}
-				if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
-					return nil, err
-				}
-				bts := make([]byte, count)
-				if err := binary.Read(buf, binary.LittleEndian, &bts); err != nil {
-					return nil, err
-				}
-				hy.ShortedFilePath = string(bts)
-				buf.Seek(24, 1)
-				if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
-					return nil, err
-				}
-				if count > 0 {
-					if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
-						return nil, err
-					}
-					buf.Seek(2, 1)
-					hy.ExtendedFilePath, err = b.utf16String(buf, count/2+1)
-					if err != nil {
-						return nil, err
-					}
-				}
 			}
 		}
-		if flag&0x8 != 0 {
-			if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
-				return nil, err
-			}
-			var bts = make([]uint16, count)
-			if err := binary.Read(buf, binary.LittleEndian, &bts); err != nil {
-				return nil, err
-			}
-			runes := utf16.Decode(bts[:len(bts)-1])
-			hy.TextMark = string(runes)
-		}
-
-		w.addRange(&hy.CellRange, &hy)
-	case 0x809:
-		buf.Seek(int64(b.Size), 1)
-	case 0xa:
-	default:
-		// log.Printf("Unknow %X,%d\n", b.Id, b.Size)
-		buf.Seek(int64(b.Size), 1)
 	}
-	if col != nil {
-		w.add(col)
+	if flag&0x8 != 0 {
+		if err := binary.Read(buf, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		hy.TextMark, err = b.utf16String(buf, count)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return b, nil
+
+	return &hy, nil
 }
 
 func (w *WorkSheet) add(content interface{}) {
@@ -251,9 +500,109 @@ func (w *WorkSheet) addCell(col Coler, ch contentHandler) {
 }
 
 func (w *WorkSheet) addRange(rang Ranger, ch contentHandler) {
+	for i := int(rang.FirstRow()); i <= int(rang.LastRow()); i++ {
+		w.addContent(uint16(i), ch)
+	}
+}
+
+// Visibility returns whether the sheet is shown, hidden or very hidden
+func (w *WorkSheet) Visibility() SheetVisibility {
+	return SheetVisibility(w.bs.Visible & 0x03)
+}
+
+// Type returns the kind of the sheet, like a worksheet or a chart
+func (w *WorkSheet) Type() SheetType {
+	return SheetType(w.bs.Type)
+}
+
+// Dimensions returns the range of the cells of the sheet, false if it has none. It is the range of the
+// DIMENSIONS record clamped to the rows and the columns of the ROW records and the cells, or the range
+// of the cells if the record is missing or does not cover them.
+func (w *WorkSheet) Dimensions() (CellRange, bool) {
+	used := CellRange{FirstRowB: 0xFFFF, FristColB: 0xFFFF}
+	found := false
+	for i, row := range w.rows {
+		for _, ch := range row.cols {
+			found = true
+			used = used.extend(i, ch.FirstCol(), ch.LastCol())
+		}
+	}
+	if !found {
+		return CellRange{}, false
+	}
+	d := w.dimensions
+	if d == nil || d.FirstRowB > used.FirstRowB || d.LastRowB < used.LastRowB ||
+		d.FristColB > used.FristColB || d.LastColB < used.LastColB {
+		return used, true
+	}
+	// the rows and the columns of the ROW records may be empty cells with a format
+	parsed := used
+	for i, row := range w.rows {
+		if row.info.Lcell > row.info.Fcell {
+			parsed = parsed.extend(i, row.info.Fcell, row.info.Lcell-1)
+		}
+	}
+	return CellRange{
+		FirstRowB: maxUint16(d.FirstRowB, parsed.FirstRowB),
+		LastRowB:  minUint16(d.LastRowB, parsed.LastRowB),
+		FristColB: maxUint16(d.FristColB, parsed.FristColB),
+		LastColB:  minUint16(d.LastColB, parsed.LastColB),
+	}, true
+}
 
-	for i := rang.FirstRow(); i <= rang.LastRow(); i++ {
-		w.addContent(i, ch)
+// clamp limits the range to the last column, false if it has no cells
+func (c *CellRange) clamp() bool {
+	if c.LastColB > 0xFF {
+		c.LastColB = 0xFF
+	}
+	return c.FirstRowB <= c.LastRowB && c.FristColB <= c.LastColB
+}
+
+// extend returns the range grown to the columns first to last of the row
+func (c CellRange) extend(row, first, last uint16) CellRange {
+	if row < c.FirstRowB {
+		c.FirstRowB = row
+	}
+	if row > c.LastRowB {
+		c.LastRowB = row
+	}
+	if first < c.FristColB {
+		c.FristColB = first
+	}
+	if last > c.LastColB {
+		c.LastColB = last
+	}
+	return c
+}
+
+func minUint16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUint16(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MergedCells returns the merged cell ranges of the sheet
+func (w *WorkSheet) MergedCells() []CellRange {
+	return w.merged
+}
+
+// addSharedFormula keeps the formula for the cells of its range, the first of them is read before it
+func (w *WorkSheet) addSharedFormula(f *sharedFormula) {
+	first := [2]uint16{f.FirstRowB, f.FristColB}
+	if w.formulas == nil {
+		w.formulas = make(map[[2]uint16]*sharedFormula)
+	}
+	w.formulas[first] = f
+	if c := w.lastFormula; c != nil && c.Header.RowB == first[0] && c.Header.FirstColB == first[1] {
+		c.shared = f
 	}
 }
 
@@ -261,11 +610,27 @@ func (w *WorkSheet) addContent(rowNo uint16, ch contentHandler) {
 	var row *Row
 	var ok bool
 	if row, ok = w.rows[rowNo]; !ok {
-		info := new(rowInfo)
+		info := w.defaultRow()
 		info.Index = rowNo
 		row = w.addRow(info)
 	}
 	row.cols[ch.FirstCol()] = ch
+	w.cells += int64(ch.LastCol()) - int64(ch.FirstCol()) + 1
+}
+
+// defaultRow returns the row information of the rows without a ROW record, from the DEFAULTROWHEIGHT record
+func (w *WorkSheet) defaultRow() *rowInfo {
+	info := &rowInfo{Height: w.defRowHeight}
+	if info.Height == 0 {
+		info.Height = defaultRowTwips
+	}
+	if w.defRowFlags&defRowCustomHeight != 0 {
+		info.Flags |= rowCustomHeight
+	}
+	if w.defRowFlags&defRowHidden != 0 {
+		info.Flags |= rowHidden
+	}
+	return info
 }
 
 func (w *WorkSheet) addRow(info *rowInfo) (row *Row) {
@@ -276,7 +641,7 @@ func (w *WorkSheet) addRow(info *rowInfo) (row *Row) {
 	if row, ok = w.rows[info.Index]; ok {
 		row.info = info
 	} else {
-		row = &Row{info: info, cols: make(map[uint16]contentHandler)}
+		row = &Row{info: info, cols: make(map[uint16]contentHandler), wb: w.wb, sheet: w}
 		w.rows[info.Index] = row
 	}
 	return
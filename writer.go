@@ -0,0 +1,471 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrNotWritable is returned by Save for workbooks read from a file, only the ones of NewWorkBook can be saved
+var ErrNotWritable = errors.New("xls: only workbooks created by NewWorkBook can be saved")
+
+// the XFs of the cells written by SetCell
+const (
+	xfGeneral  = 15
+	xfDate     = 16
+	xfDateTime = 17
+)
+
+// the custom format of date and time cells
+const (
+	fmtDateTime     = 164
+	fmtDateTimeCode = `yyyy\-mm\-dd\ hh:mm:ss`
+)
+
+// the largest body of a record, longer data goes on in CONTINUE records
+const maxRecordSize = 8224
+
+// NewWorkBook creates an empty BIFF8 workbook, add sheets with AddSheet and write it with Save
+func NewWorkBook() *WorkBook {
+	w := &WorkBook{
+		Formats:  make(map[uint16]*Format),
+		sheets:   make([]*WorkSheet, 0),
+		Codepage: 1200,
+		Type:     0x5,
+		writable: true,
+		sstIndex: make(map[string]uint32),
+	}
+	for i := 0; i < 4; i++ {
+		w.Fonts = append(w.Fonts, Font{Info: &FontInfo{Height: 200, Color: 0x7FFF, Bold: 400}, Name: "Arial"})
+	}
+	// the style XFs, the one of the cells, and the ones of dates
+	for i := 0; i < 15; i++ {
+		w.Xfs = append(w.Xfs, &Xf8{Type: 0xFFF5, Align: 0x20, Groundcolor: 0x20C0})
+	}
+	w.Xfs = append(w.Xfs,
+		&Xf8{Type: 0x1, Align: 0x20, Groundcolor: 0x20C0},
+		&Xf8{Format: 14, Type: 0x1, Align: 0x20, Usedattr: 0x04, Groundcolor: 0x20C0},
+		&Xf8{Format: fmtDateTime, Type: 0x1, Align: 0x20, Usedattr: 0x04, Groundcolor: 0x20C0},
+	)
+	f := &Format{str: fmtDateTimeCode}
+	f.Head.Index = fmtDateTime
+	f.Head.Size = uint16(len(utf16.Encode([]rune(fmtDateTimeCode))))
+	w.addFormat(f)
+	return w
+}
+
+// AddSheet appends a new empty sheet to a workbook of NewWorkBook.
+// The name must have 1 to 31 characters, none of them []:*?/\, and differ from the other sheets.
+func (w *WorkBook) AddSheet(name string) (*WorkSheet, error) {
+	if !w.writable {
+		return nil, ErrNotWritable
+	}
+	if n := len(utf16.Encode([]rune(name))); n == 0 || n > 31 || strings.ContainsAny(name, `[]:*?/\`) {
+		return nil, fmt.Errorf("xls: invalid sheet name %q", name)
+	}
+	for _, s := range w.sheets {
+		if strings.EqualFold(s.Name, name) {
+			return nil, fmt.Errorf("xls: duplicate sheet name %q", name)
+		}
+	}
+	sheet := &WorkSheet{bs: new(boundsheet), wb: w, Name: name, rows: make(map[uint16]*Row), parsed: true}
+	w.sheets = append(w.sheets, sheet)
+	return sheet, nil
+}
+
+// SetCell sets the value of the cell at the row and column, counted from 0, of a sheet of NewWorkBook.
+// The value is a string, a bool, an integer, a float, or a time.Time written as a date;
+// nil removes the cell.
+func (w *WorkSheet) SetCell(row, col int, value interface{}) error {
+	if !w.wb.writable {
+		return ErrNotWritable
+	}
+	if row < 0 || row > 0xFFFF || col < 0 || col > 0xFF {
+		return fmt.Errorf("xls: cell %d:%d out of the sheet", row, col)
+	}
+	pos := Col{RowB: uint16(row), FirstColB: uint16(col)}
+	var ch contentHandler
+	switch v := value.(type) {
+	case nil:
+		if r := w.rows[uint16(row)]; r != nil {
+			delete(r.cols, uint16(col))
+			w.updateRow(r)
+		}
+		return nil
+	case string:
+		if len(utf16.Encode([]rune(v))) > 0xFFFF {
+			return fmt.Errorf("xls: string of cell %d:%d too long", row, col)
+		}
+		ch = &LabelsstCol{Col: pos, Xf: xfGeneral, Sst: w.wb.addString(v)}
+	case bool:
+		c := &BoolErrCol{Col: pos, Xf: xfGeneral}
+		if v {
+			c.Value = 1
+		}
+		ch = c
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		f := toFloat(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("xls: %v can not be written to cell %d:%d", f, row, col)
+		}
+		ch = &NumberCol{Col: pos, Index: xfGeneral, Float: f}
+	case time.Time:
+		f, err := excelTime(v)
+		if err != nil {
+			return err
+		}
+		xf := uint16(xfDateTime)
+		if f == math.Trunc(f) {
+			xf = xfDate
+		}
+		ch = &NumberCol{Col: pos, Index: xf, Float: f}
+	default:
+		return fmt.Errorf("xls: unsupported cell value of %T", value)
+	}
+	w.addContent(uint16(row), ch)
+	w.updateRow(w.rows[uint16(row)])
+	return nil
+}
+
+// updateRow sets the columns of the row info after a change of the cells,
+// a row without cells is removed
+func (w *WorkSheet) updateRow(r *Row) {
+	if len(r.cols) == 0 {
+		delete(w.rows, r.info.Index)
+		w.MaxRow = 0
+		for i := range w.rows {
+			if i > w.MaxRow {
+				w.MaxRow = i
+			}
+		}
+		return
+	}
+	r.info.Fcell, r.info.Lcell = 0xFFFF, 0
+	for i := range r.cols {
+		if i < r.info.Fcell {
+			r.info.Fcell = i
+		}
+		if i >= r.info.Lcell {
+			r.info.Lcell = i + 1
+		}
+	}
+}
+
+// toFloat converts the numbers SetCell accepts
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// addString returns the index of the string in the shared string table, adding it if needed
+func (w *WorkBook) addString(str string) uint32 {
+	if i, ok := w.sstIndex[str]; ok {
+		return i
+	}
+	i := uint32(len(w.sst))
+	w.sst = append(w.sst, str)
+	w.sstIndex[str] = i
+	return i
+}
+
+// Save writes the workbook as a xls file, an OLE2 compound file holding the BIFF8 workbook stream
+func (w *WorkBook) Save(out io.Writer) error {
+	if !w.writable {
+		return ErrNotWritable
+	}
+	if len(w.sheets) == 0 {
+		return errors.New("xls: a workbook needs at least one sheet")
+	}
+	sheets := make([][]byte, len(w.sheets))
+	for i, s := range w.sheets {
+		sheets[i] = s.biff(i == 0)
+	}
+	// the globals are written twice, the first time to learn where the sheets begin
+	globals := w.biffGlobals()
+	pos := uint32(len(globals))
+	for i, s := range w.sheets {
+		s.bs.Filepos = pos
+		pos += uint32(len(sheets[i]))
+	}
+	stream := w.biffGlobals()
+	for _, s := range sheets {
+		stream = append(stream, s...)
+	}
+	return writeCompoundFile(out, stream)
+}
+
+// biffWriter collects the records of a substream
+type biffWriter struct {
+	bytes.Buffer
+}
+
+// record writes one record with the fields in little endian, strings must be encoded before
+func (b *biffWriter) record(id uint16, fields ...interface{}) {
+	var body bytes.Buffer
+	for _, f := range fields {
+		binary.Write(&body, binary.LittleEndian, f)
+	}
+	binary.Write(b, binary.LittleEndian, bof{ID: id, Size: uint16(body.Len())})
+	b.Write(body.Bytes())
+}
+
+// unicodeString encodes the string uncompressed, with a length of one or two bytes
+func unicodeString(str string, wide bool) []byte {
+	chars := utf16.Encode([]rune(str))
+	var buf bytes.Buffer
+	if wide {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(chars)))
+	} else {
+		buf.WriteByte(byte(len(chars)))
+	}
+	buf.WriteByte(1)
+	binary.Write(&buf, binary.LittleEndian, chars)
+	return buf.Bytes()
+}
+
+// biffGlobals encodes the workbook globals substream
+func (w *WorkBook) biffGlobals() []byte {
+	b := new(biffWriter)
+	b.record(0x809, uint16(0x600), uint16(0x5), uint16(0x0DBB), uint16(0x07CC), uint32(0), uint32(0x6))
+	b.record(0xE1, uint16(w.Codepage))             // INTERFACEHDR
+	b.record(0xC1, uint16(0))                      // MMS
+	b.record(0xE2)                                 // INTERFACEEND
+	b.record(0x5C, bytes.Repeat([]byte{' '}, 112)) // WRITEACCESS
+	b.record(0x42, uint16(w.Codepage))             // CODEPAGE
+	b.record(0x161, uint16(0))                     // DSF
+	tabs := make([]uint16, len(w.sheets))
+	for i := range tabs {
+		tabs[i] = uint16(i + 1)
+	}
+	b.record(0x13D, tabs)      // TABID
+	b.record(0x9C, uint16(14)) // FNGROUPCOUNT
+	b.record(0x19, uint16(0))  // WINDOWPROTECT
+	b.record(0x12, uint16(0))  // PROTECT
+	b.record(0x13, uint16(0))  // PASSWORD
+	b.record(0x1AF, uint16(0)) // PROT4REV
+	b.record(0x1BC, uint16(0)) // PROT4REVPASS
+	// WINDOW1
+	b.record(0x3D, uint16(0x1E0), uint16(0x5A), uint16(0x3FCF), uint16(0x2A4E), uint16(0x38), uint16(0), uint16(0), uint16(1), uint16(0x258))
+	b.record(0x40, uint16(0))  // BACKUP
+	b.record(0x8D, uint16(0))  // HIDEOBJ
+	b.record(0x22, w.dateMode) // DATEMODE
+	b.record(0x0E, uint16(1))  // PRECISION
+	b.record(0x1B7, uint16(0)) // REFRESHALL
+	b.record(0xDA, uint16(0))  // BOOKBOOL
+	for _, f := range w.Fonts {
+		i := f.Info
+		b.record(0x31, i.Height, i.Flag, i.Color, i.Bold, i.Escapement, i.Underline, i.Family, i.Charset, i.Notused, unicodeString(f.Name, false))
+	}
+	var formats []int
+	for no := range w.Formats {
+		formats = append(formats, int(no))
+	}
+	sort.Ints(formats)
+	for _, no := range formats {
+		b.record(0x41E, uint16(no), unicodeString(w.Formats[uint16(no)].str, true))
+	}
+	for _, xf := range w.Xfs {
+		b.record(0xE0, xf)
+	}
+	b.record(0x293, uint16(0x8000), byte(0), byte(0xFF)) // STYLE Normal
+	b.record(0x160, uint16(1))                           // USESELFS
+	for _, s := range w.sheets {
+		b.record(0x85, s.bs.Filepos, s.bs.Visible, s.bs.Type, unicodeString(s.Name, false))
+	}
+	b.record(0x8C, uint16(1), uint16(1)) // COUNTRY
+	w.biffSST(b)
+	b.record(0x0A)
+	return b.Bytes()
+}
+
+// biffSST writes the shared strings in a SST record and as many CONTINUE records as needed,
+// followed by the EXTSST record locating every eighth string
+func (w *WorkBook) biffSST(b *biffWriter) {
+	var refs uint32
+	for _, s := range w.sheets {
+		for _, row := range s.rows {
+			for _, ch := range row.cols {
+				if _, ok := ch.(*LabelsstCol); ok {
+					refs++
+				}
+			}
+		}
+	}
+	// EXTSST holds at most 128 buckets
+	perBucket := 8
+	if n := (len(w.sst) + 127) / 128; n > perBucket {
+		perBucket = n
+	}
+	type bucket struct {
+		pos    uint32
+		offset uint16
+	}
+	var buckets []bucket
+	var records [][]byte
+	cur := make([]byte, 8)
+	binary.LittleEndian.PutUint32(cur, refs)
+	binary.LittleEndian.PutUint32(cur[4:], uint32(len(w.sst)))
+	// the position of the current record in the stream
+	pos := uint32(b.Len())
+	next := func(flag bool) {
+		records = append(records, cur)
+		pos += 4 + uint32(len(cur))
+		cur = nil
+		if flag {
+			cur = append(cur, 1)
+		}
+	}
+	for i, str := range w.sst {
+		chars := utf16.Encode([]rune(str))
+		if len(cur)+5 > maxRecordSize {
+			next(false)
+		}
+		if i%perBucket == 0 {
+			buckets = append(buckets, bucket{pos + 4 + uint32(len(cur)), uint16(4 + len(cur))})
+		}
+		cur = append(cur, byte(len(chars)), byte(len(chars)>>8), 1)
+		for len(chars) > 0 {
+			n := (maxRecordSize - len(cur)) / 2
+			if n > len(chars) {
+				n = len(chars)
+			}
+			for _, c := range chars[:n] {
+				cur = append(cur, byte(c), byte(c>>8))
+			}
+			if chars = chars[n:]; len(chars) > 0 {
+				next(true)
+			}
+		}
+	}
+	records = append(records, cur)
+	for i, rec := range records {
+		id := uint16(0x3C)
+		if i == 0 {
+			id = 0xFC
+		}
+		b.record(id, rec)
+	}
+	ext := []interface{}{uint16(perBucket)}
+	for _, bk := range buckets {
+		ext = append(ext, bk.pos, bk.offset, uint16(0))
+	}
+	b.record(0xFF, ext...)
+}
+
+// biff encodes the worksheet substream, with the row blocks of 32 rows closed by DBCELL records
+func (w *WorkSheet) biff(selected bool) []byte {
+	b := new(biffWriter)
+	b.record(0x809, uint16(0x600), uint16(0x10), uint16(0x0DBB), uint16(0x07CC), uint32(0), uint32(0x6))
+	b.record(0x55, uint16(8)) // DEFCOLWIDTH
+	var rows []int
+	var firstCol, lastCol uint16 = 0xFFFF, 0
+	for i, row := range w.rows {
+		if len(row.cols) == 0 {
+			continue
+		}
+		rows = append(rows, int(i))
+		if row.info.Fcell < firstCol {
+			firstCol = row.info.Fcell
+		}
+		if row.info.Lcell > lastCol {
+			lastCol = row.info.Lcell
+		}
+	}
+	sort.Ints(rows)
+	if len(rows) == 0 {
+		b.record(0x200, uint32(0), uint32(0), uint16(0), uint16(0), uint16(0))
+	} else {
+		b.record(0x200, uint32(rows[0]), uint32(rows[len(rows)-1]+1), firstCol, lastCol, uint16(0))
+	}
+	for start := 0; start < len(rows); start += 32 {
+		block := rows[start:]
+		if len(block) > 32 {
+			block = block[:32]
+		}
+		firstRow := b.Len()
+		for _, i := range block {
+			info := w.rows[uint16(i)].info
+			b.record(0x208, uint16(i), info.Fcell, info.Lcell, uint16(0xFF), uint16(0), uint16(0), uint32(0x000F0100))
+		}
+		var offsets []uint16
+		prev := firstRow + 20
+		for _, i := range block {
+			first := b.Len()
+			offsets = append(offsets, uint16(first-prev))
+			prev = first
+			w.biffRow(b, w.rows[uint16(i)])
+		}
+		b.record(0xD7, uint32(b.Len()-firstRow), offsets)
+	}
+	var options uint16 = 0x00B6
+	if selected {
+		options |= 0x0600
+	}
+	// WINDOW2
+	b.record(0x23E, options, uint16(0), uint16(0), uint16(64), uint16(0), uint16(0), uint16(0), uint32(0))
+	b.record(0x0A)
+	return b.Bytes()
+}
+
+// biffRow writes the cell records of the row in the order of the columns
+func (w *WorkSheet) biffRow(b *biffWriter, row *Row) {
+	var cols []int
+	for i := range row.cols {
+		cols = append(cols, int(i))
+	}
+	sort.Ints(cols)
+	for _, i := range cols {
+		switch c := row.cols[uint16(i)].(type) {
+		case *NumberCol:
+			if rk, ok := toRK(c.Float); ok {
+				b.record(0x27E, c.RowB, c.FirstColB, c.Index, rk)
+			} else {
+				b.record(0x203, c.RowB, c.FirstColB, c.Index, c.Float)
+			}
+		case *LabelsstCol:
+			b.record(0xFD, c.RowB, c.FirstColB, c.Xf, c.Sst)
+		case *BoolErrCol:
+			b.record(0x205, c.RowB, c.FirstColB, c.Xf, c.Value, c.Flag)
+		}
+	}
+}
+
+// toRK encodes the number as RK value if it is an integer of 30 bits
+func toRK(f float64) (uint32, bool) {
+	if f != math.Trunc(f) || f < -(1<<29) || f >= 1<<29 {
+		return 0, false
+	}
+	return uint32(int32(f))<<2 | 2, true
+}
@@ -36,4 +36,5 @@ func (x *Xf8) formatNo() uint16 {
 
 type stXfData interface {
 	formatNo() uint16
+	style() Style
 }
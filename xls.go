@@ -1,13 +1,14 @@
 package xls
 
 import (
+	"context"
 	"io"
 	"os"
-
-	"github.com/extrame/ole2"
 )
 
-// Open opens one xls file with the specified charset
+// Open opens one xls file with the specified charset.
+// The charset decodes the byte strings of BIFF5 files, an empty, utf-8 or unknown charset
+// uses the codepage the file declares.
 func Open(file string, charset string) (*WorkBook, error) {
 	fi, err := os.Open(file)
 	if err != nil {
@@ -27,36 +28,45 @@ func OpenWithCloser(file string, charset string) (*WorkBook, io.Closer, error) {
 	return wb, fi, err
 }
 
-// OpenReader opens a xls file from reader
+// OpenReader opens a xls file from reader, the sheets are read from it when they are loaded
+// so it has to stay open while the workbook is used
 func OpenReader(reader io.ReadSeeker, charset string) (wb *WorkBook, err error) {
-	ole, err := ole2.Open(reader, charset)
+	return OpenReaderContext(context.Background(), reader, Options{Charset: charset})
+}
+
+// OpenReaderContext opens a xls file from reader, it stops when ctx is done and returns an error
+// wrapping ErrLimit when the file exceeds the limits of opts. The workbook keeps the options
+// and applies them when its sheets are parsed.
+func OpenReaderContext(ctx context.Context, reader io.ReadSeeker, opts Options) (wb *WorkBook, err error) {
+	enc := charsetEncoding(opts.Charset)
+	f, err := openOleFile(reader)
 	if err != nil {
 		return nil, err
 	}
-
-	dir, err := ole.ListDir()
+	book := f.find("Workbook", "Book")
+	if book == nil {
+		return nil, nil
+	}
+	stream, err := f.stream(book)
 	if err != nil {
 		return nil, err
 	}
-
-	var book *ole2.File
-	var root *ole2.File
-	for _, file := range dir {
-		name := file.Name()
-		if name == "Workbook" && book == nil {
-			book = file
-		}
-		if name == "Book" {
-			book = file
-			// break
-		}
-		if name == "Root Entry" {
-			root = file
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if book == nil {
-		return wb, nil
+	wb, err = newWorkBookFromOle2(ctx, stream, enc, opts)
+	if err != nil {
+		return nil, err
 	}
-	return newWorkBookFromOle2(ole.OpenFile(book, root))
+	wb.readMetadata(readOleStream(f, "\x05SummaryInformation"), readOleStream(f, "\x05DocumentSummaryInformation"))
+	return wb, nil
+}
 
+// readOleStream reads the whole content of a stream, nil if it is missing or can not be read
+func readOleStream(f *oleFile, name string) []byte {
+	if file := f.find(name); file != nil {
+		bts, _ := f.read(file)
+		return bts
+	}
+	return nil
 }
@@ -1,11 +1,509 @@
 package xls
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 	"unicode/utf16"
 )
 
+// record encodes one BIFF record with the given fields in little endian
+func record(id uint16, fields ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, f := range fields {
+		binary.Write(&body, binary.LittleEndian, f)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, bof{ID: id, Size: uint16(body.Len())})
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// sectionOf returns the workbook stream of the bytes
+func sectionOf(stream []byte) *io.SectionReader {
+	return io.NewSectionReader(bytes.NewReader(stream), 0, int64(len(stream)))
+}
+
+// parseSheet parses the records as the content of a BIFF8 worksheet
+func parseSheet(t *testing.T, records ...[]byte) *WorkSheet {
+	return parseBookSheet(t, &WorkBook{Formats: make(map[uint16]*Format)}, records...)
+}
+
+// parseBookSheet parses the records as the content of a worksheet of the workbook
+func parseBookSheet(t *testing.T, wb *WorkBook, records ...[]byte) *WorkSheet {
+	sheet := &WorkSheet{wb: wb, bs: new(boundsheet)}
+	records = append(records, record(0x0a))
+	if err := sheet.parse(context.Background(), bytes.NewReader(bytes.Join(records, nil)), 0); err != nil {
+		t.Fatal(err)
+	}
+	return sheet
+}
+
+// formula encodes a FORMULA record with the cached result and no tokens
+func formula(row, col uint16, result [8]byte) []byte {
+	return record(0x06, row, col, uint16(15), result, uint16(0), uint32(0), uint16(0))
+}
+
+// formulaTokens encodes a FORMULA record with the tokens and the extra data of constant arrays
+func formulaTokens(row, col uint16, tokens []byte, extra []byte) []byte {
+	return record(0x06, row, col, uint16(15), [8]byte{}, uint16(0), uint32(0), uint16(len(tokens)), tokens, extra)
+}
+
+// fields encodes the fields in little endian
+func fields(values ...interface{}) []byte {
+	return record(0, values...)[4:]
+}
+
+func TestFormula(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	err := wb.Parse(bytes.NewReader(bytes.Join([][]byte{
+		record(0x85, uint32(0), uint16(0), byte(6), byte(0), []byte("Sheet1")),
+		record(0x85, uint32(0), uint16(0), byte(7), byte(0), []byte("2nd one")),
+		record(0x1AE, uint16(2), uint16(0x0401)),
+		record(0x1AE, uint16(1), uint16(0x3A01)),
+		record(0x23, uint16(0), uint32(0), byte(13), byte(0), []byte("_xlfn.IFERROR")),
+		record(0x17, uint16(3), []uint16{0, 1, 1, 1, 0xFFFE, 0xFFFE, 0, 0, 1}),
+		record(0x18, uint16(0), byte(0), byte(5), uint16(0), uint16(0), uint16(0), uint32(0), byte(0), []byte("Total")),
+	}, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := parseBookSheet(t, wb,
+		// SUM(A1:B3)*'2nd one'!C4
+		formulaTokens(0, 0, fields(byte(0x25), uint16(0), uint16(2), uint16(0xC000), uint16(0xC001),
+			byte(0x42), byte(1), uint16(4), byte(0x5A), uint16(0), uint16(3), uint16(0xC002), byte(0x05)), nil),
+		// IF($A$1>0,"a""b",-1.5%)
+		formulaTokens(0, 1, fields(byte(0x24), uint16(0), uint16(0), byte(0x1E), uint16(0), byte(0x0D),
+			byte(0x17), byte(3), byte(0), []byte(`a"b`), byte(0x1F), 1.5, byte(0x14), byte(0x13),
+			byte(0x42), byte(3), uint16(1)), nil),
+		// IFERROR(Total,{1,"x";TRUE,#N/A})
+		formulaTokens(0, 2, fields(byte(0x39), uint16(1), uint16(1), uint16(0), byte(0x23), uint16(1), uint16(0),
+			byte(0x60), [7]byte{}, byte(0x42), byte(3), uint16(255)),
+			fields(byte(1), uint16(1), byte(1), 1.0, byte(2), uint16(1), byte(0), []byte("x"),
+				byte(4), [8]byte{1}, byte(0x10), [8]byte{0x2A})),
+		// ROUND(A:A,2)+Sheet1:'2nd one'!$B$2:C$3
+		formulaTokens(0, 3, fields(byte(0x25), uint16(0), uint16(0xFFFF), uint16(0xC000), uint16(0xC000),
+			byte(0x1E), uint16(2), byte(0x41), uint16(27),
+			byte(0x3B), uint16(2), uint16(1), uint16(2), uint16(1), uint16(0x4002), byte(0x03)), nil),
+		// a broken token stream
+		formulaTokens(0, 4, fields(byte(0x24), uint16(0)), nil),
+	)
+	row := sheet.Row(0)
+	for i, want := range []string{
+		"=SUM(A1:B3)*'2nd one'!C4",
+		`=IF($A$1>0,"a""b",-1.5%)`,
+		`=IFERROR(Total,{1,"x";TRUE,#N/A})`,
+		"=ROUND(A:A,2)+'Sheet1:2nd one'!$B$2:C$3",
+		"",
+	} {
+		if got := row.Cell(i).Formula(); got != want {
+			t.Errorf("col %d is %s instead of %s", i, got, want)
+		}
+	}
+	if _, err := sheet.rows[0].cols[4].(*FormulaCol).Formula(wb); !errors.Is(err, errFormula) {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestBrokenNames(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	err := wb.Parse(bytes.NewReader(bytes.Join([][]byte{
+		record(0x85, uint32(0), uint16(0), byte(6), byte(0), []byte("Sheet1")),
+		record(0x1AE, uint16(1), uint16(0x0401)),
+		// a SUPBOOK with a path longer than the record
+		record(0x1AE, uint16(1), uint16(40), byte(0), []byte("a.xls")),
+		record(0x23, uint16(0), uint32(0), byte(4)),
+		record(0x17, uint16(2), []uint16{0, 0, 0, 1, 0, 0}),
+		// a NAME shorter than its header
+		record(0x18, uint16(0), byte(0), byte(5)),
+		record(0x18, uint16(0), byte(0), byte(5), uint16(0), uint16(0), uint16(0), uint32(0), byte(0), []byte("Total")),
+	}, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := parseBookSheet(t, wb,
+		formulaTokens(0, 0, fields(byte(0x23), uint16(2), uint16(0)), nil),
+		formulaTokens(0, 1, fields(byte(0x23), uint16(1), uint16(0)), nil),
+		formulaTokens(0, 2, fields(byte(0x3A), uint16(1), uint16(0), uint16(0)), nil),
+	)
+	if got := sheet.Row(0).Cell(0).Formula(); got != "=Total" {
+		t.Errorf("formula is %s", got)
+	}
+	for i := 1; i < 3; i++ {
+		if _, err := sheet.rows[0].cols[uint16(i)].(*FormulaCol).Formula(wb); !errors.Is(err, errFormula) {
+			t.Errorf("col %d has error %v", i, err)
+		}
+	}
+}
+
+func TestSharedFormula(t *testing.T) {
+	exp := func(row, col uint16) []byte {
+		return fields(byte(0x01), row, col)
+	}
+	// A1*2 relative to column B, for B1:B3
+	shared := fields(uint16(9), byte(0x2C), uint16(0), uint16(0xC0FF), byte(0x1E), uint16(2), byte(0x05))
+	// SUM($A$1:$A$3) for D1:D2
+	array := fields(uint16(13), byte(0x25), uint16(0), uint16(2), uint16(0), uint16(0), byte(0x42), byte(1), uint16(4))
+	sheet := parseSheet(t,
+		formulaTokens(0, 1, exp(0, 1), nil),
+		record(0x4BC, uint16(0), uint16(2), byte(1), byte(1), byte(0), byte(3), shared),
+		formulaTokens(1, 1, exp(0, 1), nil),
+		formulaTokens(2, 1, exp(0, 1), nil),
+		formulaTokens(0, 3, exp(0, 3), nil),
+		record(0x221, uint16(0), uint16(1), byte(3), byte(3), uint16(0), uint32(0), array),
+		formulaTokens(1, 3, exp(0, 3), nil),
+	)
+	for i, want := range []string{"=A1*2", "=A2*2", "=A3*2"} {
+		cell := sheet.Row(i).Cell(1)
+		if got := cell.Formula(); got != want {
+			t.Errorf("row %d is %s instead of %s", i, got, want)
+		}
+		if r, ok := cell.FormulaRange(); !ok || r != (CellRange{0, 2, 1, 1}) || cell.IsArrayFormula() {
+			t.Errorf("row %d has range %v, %v", i, r, ok)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		cell := sheet.Row(i).Cell(3)
+		if got := cell.Formula(); got != "{=SUM($A$1:$A$3)}" {
+			t.Errorf("row %d is %s", i, got)
+		}
+		if r, ok := cell.FormulaRange(); !ok || r != (CellRange{0, 1, 3, 3}) || !cell.IsArrayFormula() {
+			t.Errorf("row %d has range %v, %v", i, r, ok)
+		}
+	}
+}
+
+func TestMergedCells(t *testing.T) {
+	records := [][]byte{
+		record(0x203, uint16(0), uint16(2), uint16(0), 1.5),
+		record(0x201, uint16(0), uint16(3), uint16(0)),
+		record(0x201, uint16(1), uint16(4), uint16(0)),
+		record(0xE5, uint16(1), CellRange{0, 1, 2, 5}),
+	}
+	sheet := parseSheet(t, records...)
+	if got := sheet.MergedCells(); len(got) != 1 || got[0] != (CellRange{0, 1, 2, 5}) {
+		t.Fatalf("merged cells are %v", got)
+	}
+	if c := sheet.Row(0).Cell(3); c.Kind() != CellBlank {
+		t.Errorf("covered cell is %s", c.Kind())
+	}
+
+	wb := &WorkBook{Formats: make(map[uint16]*Format), opts: Options{FillMergedCells: true}}
+	sheet = parseBookSheet(t, wb, records...)
+	for _, pos := range [][2]int{{0, 2}, {0, 3}, {1, 4}} {
+		if f, err := sheet.Row(pos[0]).Cell(pos[1]).Float(); err != nil || f != 1.5 {
+			t.Errorf("cell %v is %v, %v", pos, f, err)
+		}
+		if s := sheet.Row(pos[0]).Col(pos[1]); s != "1.5" {
+			t.Errorf("cell %v is %q", pos, s)
+		}
+	}
+	if c := sheet.Row(0).Cell(6); c.Kind() != CellBlank {
+		t.Errorf("cell right of the range is %s", c.Kind())
+	}
+
+	// the broken ranges are skipped, the range past the last column is clamped and the short list ends
+	sheet = parseSheet(t,
+		record(0xE5, uint16(5), CellRange{0, 1, 2, 5}, CellRange{3, 2, 0, 0}, CellRange{4, 4, 6, 2},
+			CellRange{5, 5, 200, 0x100}),
+		// a hyperlink with its rows the wrong way round
+		record(0x1b8, uint16(5), uint16(4), uint16(0), uint16(0), [20]byte{}, uint32(0)),
+	)
+	if got := sheet.MergedCells(); len(got) != 2 || got[1] != (CellRange{5, 5, 200, 0xFF}) {
+		t.Errorf("merged cells are %v", got)
+	}
+	if row := sheet.Row(4); row != nil {
+		t.Errorf("hyperlink of a broken range in row 4")
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	cells := [][]byte{
+		record(0x203, uint16(2), uint16(1), uint16(0), 1.0),
+		record(0x203, uint16(5), uint16(3), uint16(0), 2.0),
+	}
+	for _, c := range []struct {
+		dims []byte
+		want CellRange
+	}{
+		{nil, CellRange{2, 5, 1, 3}},
+		// an oversized record is clamped to the rows and the columns of the ROW records and the cells
+		{record(0x200, uint32(0), uint32(10), uint16(0), uint16(8), uint16(0)), CellRange{2, 7, 1, 5}},
+		{record(0x200, uint32(0), uint32(0xFFFF), uint16(0), uint16(0xFF), uint16(0)), CellRange{2, 7, 1, 5}},
+		{record(0x200, uint32(2), uint32(6), uint16(0), uint16(5), uint16(0)), CellRange{2, 5, 1, 4}},
+		// a record not covering the cells is ignored
+		{record(0x200, uint32(0), uint32(2), uint16(0), uint16(8), uint16(0)), CellRange{2, 5, 1, 3}},
+	} {
+		row := record(0x208, uint16(7), uint16(2), uint16(6), uint16(300), uint16(0), uint16(0), uint32(0x100))
+		sheet := parseSheet(t, append([][]byte{c.dims, row}, cells...)...)
+		if got, ok := sheet.Dimensions(); !ok || got != c.want {
+			t.Errorf("dimensions are %v instead of %v", got, c.want)
+		}
+	}
+	sheet := parseSheet(t, record(0x200, uint32(0), uint32(10), uint16(0), uint16(8), uint16(0)))
+	if got, ok := sheet.Dimensions(); ok {
+		t.Errorf("dimensions of an empty sheet are %v", got)
+	}
+}
+
+func TestSheetVisibility(t *testing.T) {
+	wb := NewWorkBook()
+	for i, name := range []string{"shown", "hidden", "very hidden", "chart"} {
+		sheet, _ := wb.AddSheet(name)
+		sheet.SetCell(0, 0, i)
+	}
+	wb.sheets[1].bs.Visible = byte(SheetHidden)
+	wb.sheets[2].bs.Visible = byte(SheetVeryHidden)
+	wb.sheets[3].bs.Type = byte(SheetChart)
+	var buf bytes.Buffer
+	if err := wb.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := OpenReader(bytes.NewReader(buf.Bytes()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		visibility SheetVisibility
+		kind       SheetType
+	}{{SheetVisible, SheetWorksheet}, {SheetHidden, SheetWorksheet}, {SheetVeryHidden, SheetWorksheet}, {SheetVisible, SheetChart}} {
+		sheet := read.GetSheet(i)
+		if sheet.Visibility() != want.visibility || sheet.Type() != want.kind {
+			t.Errorf("sheet %d is a %s %s", i, sheet.Visibility(), sheet.Type())
+		}
+	}
+	sheets, err := read.VisibleSheets()
+	if err != nil || len(sheets) != 1 || sheets[0].Name != "shown" {
+		t.Errorf("visible sheets are %v, %v", sheets, err)
+	}
+}
+
+func TestStyle(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	err := wb.Parse(bytes.NewReader(bytes.Join([][]byte{
+		record(0xE0, Xf8{Type: 0xFFF5}),
+		// centered wrapped text turned 45 degrees clockwise, indented twice with borders and a fill
+		record(0xE0, Xf8{Font: 5, Format: 14, Type: 0x0003, Align: 0x1A, Rotation: 135, Ident: 0x12,
+			Linestyle: 1 | 2<<4 | 3<<8 | 6<<12 | 8<<16 | 10<<23, Linecolor: 12 | 0x40<<7 | 1<<26, Groundcolor: 10 | 0x41<<7}),
+	}, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := parseBookSheet(t, wb, record(0x201, uint16(0), uint16(0), uint16(1)))
+	style, ok := sheet.Row(0).Cell(0).Style()
+	want := Style{FontIndex: 5, FormatIndex: 14, HorizontalAlign: AlignCenter, VerticalAlign: AlignMiddle, Wrap: true,
+		ShrinkToFit: true, Rotation: -45, Indent: 2,
+		Left: Border{BorderThin, 8}, Right: Border{BorderMedium, 10}, Top: Border{BorderDashed, 12}, Bottom: Border{BorderDouble, 0x40},
+		Fill: FillSolid, ForegroundColor: 10, BackgroundColor: 0x41, Locked: true, Hidden: true}
+	if !ok || style != want {
+		t.Errorf("style is %+v, want %+v", style, want)
+	}
+	if style, _ := wb.Style(0); !style.IsStyle || style.Parent != 0 || !style.Locked {
+		t.Errorf("style XF is %+v", style)
+	}
+	if _, ok := wb.Style(2); ok {
+		t.Error("missing XF has a style")
+	}
+
+	wb = &WorkBook{Is5ver: true, Formats: make(map[uint16]*Format)}
+	err = wb.Parse(bytes.NewReader(record(0xE0, Xf5{Font: 1, Type: 0x0011, Align: 0x0323, Color: 9 | 10<<7,
+		Fill: 4 | 5<<6 | 11<<9, Border: 1 | 2<<3 | 7<<6 | 12<<9, Linestyle: 13 | 14<<7})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Style{FontIndex: 1, HorizontalAlign: AlignRight, VerticalAlign: AlignBottom, Rotation: -90,
+		Left: Border{BorderMedium, 13}, Right: Border{BorderHair, 14}, Top: Border{BorderThin, 12}, Bottom: Border{BorderThick, 11},
+		Fill: 4, ForegroundColor: 9, BackgroundColor: 10, Locked: true, Parent: 1}
+	if style, _ := wb.Style(0); style != want {
+		t.Errorf("BIFF5 style is %+v, want %+v", style, want)
+	}
+}
+
+func TestFont(t *testing.T) {
+	var records [][]byte
+	for i := 0; i < 4; i++ {
+		records = append(records, record(0x31, FontInfo{Height: 200, Bold: 400, Color: 0x7FFF, NameB: 5}, byte(0), []byte("Arial")))
+	}
+	records = append(records,
+		record(0x31, FontInfo{Height: 230, Flag: 0x0A, Color: 10, Bold: 700, Escapement: 2, Underline: 0x22, NameB: 7}, byte(0), []byte("Calibri")),
+		record(0xE0, Xf8{Font: 0, Type: 0xFFF5}),
+		record(0xE0, Xf8{Font: 5, Type: 0x0001}),
+	)
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	if err := wb.Parse(bytes.NewReader(bytes.Join(records, nil))); err != nil {
+		t.Fatal(err)
+	}
+	if wb.Font(4) != nil {
+		t.Error("font 4 exists")
+	}
+	sheet := parseBookSheet(t, wb, record(0x201, uint16(0), uint16(0), uint16(1)), record(0x201, uint16(0), uint16(1), uint16(0)))
+	font := sheet.Row(0).Cell(0).Font()
+	if font == nil || font.Name != "Calibri" {
+		t.Fatalf("font is %+v", font)
+	}
+	info := font.Info
+	if info.Size() != 11.5 || !info.Italic() || !info.Strikeout() || !info.IsBold() || info.Weight() != 700 ||
+		info.UnderlineKind() != UnderlineDoubleAccounting || info.Script() != ScriptSub || info.ColorIndex() != 10 || info.IsAutoColor() {
+		t.Errorf("font info is %+v", info)
+	}
+	if font := sheet.Row(0).Cell(1).Font(); font == nil || font.Name != "Arial" || font.Info.Italic() || font.Info.IsBold() || !font.Info.IsAutoColor() {
+		t.Errorf("default font is %+v", font)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	if c, ok := wb.Color(53); !ok || c.Hex() != "FF6600" {
+		t.Errorf("default color 53 is %v", c)
+	}
+	err := wb.Parse(bytes.NewReader(record(0x92, uint16(2), [4]byte{0x12, 0x34, 0x56}, [4]byte{0xAB, 0xCD, 0xEF})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, want := range map[uint16]string{2: "FF0000", 8: "123456", 9: "ABCDEF", 10: "FF0000", 63: "333333", 0x40: "000000", 0x41: "FFFFFF", 0x7FFF: "000000", 64 + 2: ""} {
+		if hex := wb.ColorHex(index); hex != want {
+			t.Errorf("color %d is %q, want %q", index, hex, want)
+		}
+	}
+	if palette := wb.Palette(); len(palette) != 56 || palette[1].HTML() != "#ABCDEF" || palette[2].HTML() != "#FF0000" {
+		t.Errorf("palette is %v", palette)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	sheet := parseSheet(t,
+		record(0x55, uint16(10)),
+		record(0x7D, uint16(1), uint16(2), uint16(2340), uint16(17), uint16(0x0101), uint16(0)),
+		record(0x7D, uint16(3), uint16(3), uint16(512), uint16(15), uint16(0x1000), uint16(0)),
+		// the broken ranges are skipped and the range past the last column is clamped
+		record(0x7D, uint16(4), uint16(1), uint16(1024), uint16(15), uint16(0), uint16(0)),
+		record(0x7D, uint16(300), uint16(400), uint16(1024), uint16(15), uint16(0), uint16(0)),
+		record(0x7D, uint16(200), uint16(0x100), uint16(1024), uint16(15), uint16(0), uint16(0)),
+	)
+	for i, want := range map[int]ColumnInfo{
+		0:    {Width: 80.0 / 7, XF: 15},
+		1:    {Width: 2340.0 / 256, Hidden: true, OutlineLevel: 1, XF: 17},
+		2:    {Width: 2340.0 / 256, Hidden: true, OutlineLevel: 1, XF: 17},
+		3:    {Width: 2, Collapsed: true, XF: 15},
+		4:    {Width: 80.0 / 7, XF: 15},
+		199:  {Width: 80.0 / 7, XF: 15},
+		0xFF: {Width: 4, XF: 15},
+	} {
+		if c := sheet.Column(i); c != want {
+			t.Errorf("column %d is %+v, want %+v", i, c, want)
+		}
+	}
+	if px := sheet.Column(1).Pixels(); px != 64 {
+		t.Errorf("column 1 is %d pixels wide", px)
+	}
+	if px := parseSheet(t).Column(0).Pixels(); px != 64 {
+		t.Errorf("default column is %d pixels wide", px)
+	}
+	sheet = parseSheet(t, record(0x55, uint16(10)), record(0x99, uint16(3000)))
+	if w := sheet.DefaultColumnWidth(); w != 3000.0/256 {
+		t.Errorf("standard width is %v", w)
+	}
+}
+
+func TestRowInfo(t *testing.T) {
+	sheet := parseSheet(t,
+		record(0x225, uint16(0x0001), uint16(300)),
+		record(0x208, uint16(0), uint16(0), uint16(1), uint16(400), uint16(0), uint16(0), uint32(17<<16|0x01F2)),
+		record(0x201, uint16(0), uint16(0), uint16(15)),
+		record(0x201, uint16(1), uint16(0), uint16(15)),
+	)
+	row := sheet.Row(0)
+	if xf, ok := row.XF(); row.Height() != 20 || !row.CustomHeight() || !row.Hidden() || row.OutlineLevel() != 2 || !row.Collapsed() || !ok || xf != 17 {
+		t.Errorf("row 0 has height %v, custom %v, hidden %v, level %d, collapsed %v, XF %d %v",
+			row.Height(), row.CustomHeight(), row.Hidden(), row.OutlineLevel(), row.Collapsed(), xf, ok)
+	}
+	row = sheet.Row(1)
+	if _, ok := row.XF(); row.Height() != 15 || !row.CustomHeight() || row.Hidden() || row.OutlineLevel() != 0 || ok {
+		t.Errorf("row 1 without ROW record has height %v, custom %v, hidden %v", row.Height(), row.CustomHeight(), row.Hidden())
+	}
+	if row := parseSheet(t, record(0x201, uint16(0), uint16(0), uint16(15))).Row(0); row.Height() != 12.75 || row.CustomHeight() {
+		t.Errorf("default row has height %v", row.Height())
+	}
+	// an empty row has no Row, its format is the default of the sheet
+	if row := sheet.Row(5); row != nil || sheet.DefaultRowHeight() != 15 || !sheet.DefaultRowCustomHeight() || sheet.DefaultRowHidden() {
+		t.Errorf("empty row %v, default height %v, custom %v, hidden %v",
+			row, sheet.DefaultRowHeight(), sheet.DefaultRowCustomHeight(), sheet.DefaultRowHidden())
+	}
+	sheet = parseSheet(t, record(0x225, uint16(0x0002), uint16(0)))
+	if sheet.DefaultRowHeight() != 12.75 || sheet.DefaultRowCustomHeight() || !sheet.DefaultRowHidden() {
+		t.Errorf("hidden default row has height %v, custom %v", sheet.DefaultRowHeight(), sheet.DefaultRowCustomHeight())
+	}
+	if sheet = parseSheet(t); sheet.DefaultRowHeight() != 12.75 || sheet.DefaultRowHidden() {
+		t.Errorf("sheet without DEFAULTROWHEIGHT has height %v", sheet.DefaultRowHeight())
+	}
+}
+
+func TestRichText(t *testing.T) {
+	utf16le := func(s string) []uint16 {
+		return utf16.Encode([]rune(s))
+	}
+	var records [][]byte
+	for i := 0; i < 5; i++ {
+		records = append(records, record(0x31, FontInfo{Height: 200, NameB: 2}, byte(0), []byte(fmt.Sprint("F", i))))
+	}
+	phonetic := fields(uint16(1), uint16(22), uint16(0), uint16(0), uint16(1), uint16(2), uint16(3), utf16le("かんじ"),
+		uint16(0), uint16(0), uint16(2))
+	records = append(records,
+		// the characters of the rich string continue in the next record, followed by its run
+		record(0xFC, uint32(2), uint32(2), uint16(6), byte(0x08), uint16(1), []byte("abc")),
+		record(0x3C, byte(0), []byte("def"), uint16(3), uint16(3),
+			uint16(2), byte(0x05), uint32(len(phonetic)), utf16le("漢字"), phonetic),
+	)
+	wb := &WorkBook{Formats: make(map[uint16]*Format)}
+	if err := wb.Parse(bytes.NewReader(bytes.Join(records, nil))); err != nil {
+		t.Fatal(err)
+	}
+	sheet := parseBookSheet(t, wb,
+		record(0xFD, uint16(0), uint16(0), uint16(0), uint32(0)),
+		record(0xFD, uint16(0), uint16(1), uint16(0), uint32(1)),
+	)
+	cell := sheet.Row(0).Cell(0)
+	runs := cell.RichText()
+	if cell.String() != "abcdef" || len(runs) != 1 {
+		t.Fatalf("cell is %q with runs %v", cell.String(), runs)
+	}
+	if r := runs[0]; r.Start != 3 || r.Text != "def" || r.FontIndex != 3 || r.Font == nil || r.Font.Name != "F3" {
+		t.Errorf("run is %+v", r)
+	}
+	cell = sheet.Row(0).Cell(1)
+	if cell.String() != "漢字" || cell.Phonetic() != "かんじ" || cell.RichText() != nil {
+		t.Errorf("cell is %q with phonetic %q", cell.String(), cell.Phonetic())
+	}
+}
+
+func TestFormulaResult(t *testing.T) {
+	var num [8]byte
+	binary.LittleEndian.PutUint64(num[:], math.Float64bits(2.5))
+	sheet := parseSheet(t,
+		formula(0, 0, num),
+		formula(0, 1, [8]byte{0, 0, 0, 0, 0, 0, 0xff, 0xff}),
+		record(0x207, uint16(3), byte(0), []byte("abc")),
+		formula(0, 2, [8]byte{1, 0, 1, 0, 0, 0, 0xff, 0xff}),
+		formula(0, 3, [8]byte{2, 0, 0x07, 0, 0, 0, 0xff, 0xff}),
+		formula(0, 4, [8]byte{3, 0, 0, 0, 0, 0, 0xff, 0xff}),
+	)
+	row := sheet.Row(0)
+	for i, want := range []string{"2.5", "abc", "TRUE", "#DIV/0!", ""} {
+		if got := row.Col(i); got != want {
+			t.Errorf("col %d is %q instead of %q", i, got, want)
+		}
+	}
+}
+
 func TestOpen(t *testing.T) {
 	if xlFile, err := Open("t1.xls", "utf-8"); err == nil {
 		if sheet1 := xlFile.GetSheet(0); sheet1 != nil {
@@ -85,3 +583,444 @@ func TestEuropeString(t *testing.T) {
 // 		}
 // 	}
 // }
+
+func TestCellKinds(t *testing.T) {
+	wb := &WorkBook{Formats: make(map[uint16]*Format), sst: []string{"text"}}
+	sheet := parseBookSheet(t, wb,
+		record(0x203, uint16(0), uint16(0), uint16(0), 1.5),
+		record(0x203, uint16(0), uint16(1), uint16(1), 45000.0),
+		record(0x27e, uint16(0), uint16(2), uint16(0), uint32(42<<2|2)),
+		record(0xFD, uint16(0), uint16(3), uint16(0), uint32(0)),
+		formula(0, 4, [8]byte{1, 0, 1, 0, 0, 0, 0xff, 0xff}),
+	)
+	sheet.wb.Xfs = []stXfData{&Xf8{}, &Xf8{Format: 14}}
+	row := sheet.Row(0)
+
+	if c := row.Cell(0); c.Kind() != CellNumber {
+		t.Errorf("col 0 is %s", c.Kind())
+	} else if f, _ := c.Float(); f != 1.5 {
+		t.Errorf("col 0 is %v", f)
+	}
+	if c := row.Cell(1); c.Kind() != CellDate {
+		t.Errorf("col 1 is %s", c.Kind())
+	} else if tm, _ := c.Time(); tm.Format("2006-01-02") != "2023-03-15" {
+		t.Errorf("col 1 is %v", tm)
+	}
+	if i, err := row.Cell(2).Int(); err != nil || i != 42 {
+		t.Errorf("col 2 is %v, %v", i, err)
+	}
+	if c := row.Cell(3); c.Kind() != CellString || c.String() != "text" {
+		t.Errorf("col 3 is %s %q", c.Kind(), c.String())
+	}
+	if _, err := row.Cell(3).Float(); err == nil {
+		t.Error("string cell converted to float")
+	}
+	if c := row.Cell(4); c.Kind() != CellFormula || c.ResultKind() != CellBool {
+		t.Errorf("col 4 is %s of %s", c.Kind(), c.ResultKind())
+	} else if b, _ := c.Bool(); !b {
+		t.Error("col 4 is false")
+	}
+	if c := row.Cell(5); c.Kind() != CellBlank {
+		t.Errorf("col 5 is %s", c.Kind())
+	}
+}
+
+func TestBoolErr(t *testing.T) {
+	sheet := parseSheet(t,
+		record(0x205, uint16(0), uint16(0), uint16(0), byte(1), byte(0)),
+		record(0x205, uint16(0), uint16(1), uint16(0), byte(0), byte(0)),
+		record(0x205, uint16(0), uint16(2), uint16(0), byte(ErrorNA), byte(1)),
+	)
+	row := sheet.Row(0)
+	for i, want := range []string{"TRUE", "FALSE", "#N/A"} {
+		if got := row.Col(i); got != want {
+			t.Errorf("col %d is %q instead of %q", i, got, want)
+		}
+	}
+	if b, err := row.Cell(0).Bool(); err != nil || !b {
+		t.Errorf("col 0 is %v, %v", b, err)
+	}
+	if code, err := row.Cell(2).ErrorCode(); err != nil || code != ErrorNA {
+		t.Errorf("col 2 is %v, %v", code, err)
+	}
+	if _, err := row.Cell(2).Bool(); err == nil {
+		t.Error("error cell converted to bool")
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	var num [8]byte
+	binary.LittleEndian.PutUint64(num[:], math.Float64bits(0.125))
+	sheet := parseSheet(t,
+		record(0x203, uint16(0), uint16(0), uint16(1), 44927.0),
+		record(0x203, uint16(0), uint16(1), uint16(2), 1234.5),
+		record(0x06, uint16(0), uint16(2), uint16(3), num, uint16(0), uint32(0), uint16(0)),
+	)
+	sheet.wb.Xfs = []stXfData{&Xf8{}, &Xf8{Format: 14}, &Xf8{Format: 4}, &Xf8{Format: 10}}
+	row := sheet.Row(0)
+	for i, want := range []string{"1/1/23", "1,234.50", "12.50%"} {
+		if got := row.Col(i); got != want {
+			t.Errorf("col %d is %q instead of %q", i, got, want)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	wb, err := Open("Table.xls", "utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wb.Author != "huangxiong" || wb.Metadata.Author != wb.Author {
+		t.Errorf("author is %q", wb.Author)
+	}
+	if wb.Metadata.Application != "Microsoft Macintosh Excel" {
+		t.Errorf("application is %q", wb.Metadata.Application)
+	}
+	if created := time.Date(2015, 8, 23, 8, 3, 0, 0, time.UTC); !wb.Metadata.Created.Equal(created) {
+		t.Errorf("created at %v", wb.Metadata.Created)
+	}
+	if wb.Metadata.Modified.Before(wb.Metadata.Created) {
+		t.Errorf("modified at %v", wb.Metadata.Modified)
+	}
+}
+
+func TestBiff5Codepage(t *testing.T) {
+	cases := []struct {
+		codepage uint16
+		charset  string
+		bts      []byte
+		want     string
+	}{
+		{1252, "", []byte("caf\xe9"), "café"},
+		{1251, "", []byte("\xcf\xf0\xe8"), "При"},
+		{932, "", []byte("\x93\xfa\x96\x7b"), "日本"},
+		{10000, "", []byte("caf\x8e"), "café"},
+		{0, "", []byte("caf\xe9"), "café"},
+		{1252, "cp1251", []byte("\xcf\xf0\xe8"), "При"},
+		{1252, "shift_jis", []byte("\x93\xfa\x96\x7b"), "日本"},
+		{1251, "none", []byte("\xcf\xf0\xe8"), "При"},
+		{1251, "cp99", []byte("\xcf\xf0\xe8"), "При"},
+		{932, "UTF-8", []byte("\x93\xfa\x96\x7b"), "日本"},
+	}
+	for _, c := range cases {
+		wb := &WorkBook{Is5ver: true, Codepage: c.codepage, charset: charsetEncoding(c.charset)}
+		if str, _ := wb.getString(bytes.NewReader(c.bts), uint16(len(c.bts))); str != c.want {
+			t.Errorf("codepage %d charset %q decodes %q instead of %q", c.codepage, c.charset, str, c.want)
+		}
+	}
+	wb := NewWorkBook()
+	wb.AddSheet("Sheet1")
+	var buf bytes.Buffer
+	if err := wb.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenReader(bytes.NewReader(buf.Bytes()), "none"); err != nil {
+		t.Errorf("unknown charset fails the open: %v", err)
+	}
+}
+
+func TestSheetParseError(t *testing.T) {
+	number := record(0x203, uint16(0), uint16(0), uint16(15), float64(1))
+	blank := record(0x201, uint16(1), uint16(0), uint16(15))
+	stream := append(append([]byte{}, number...), blank[:len(blank)-2]...)
+	wb := &WorkBook{Formats: make(map[uint16]*Format), rs: sectionOf(stream)}
+	wb.sheets = append(wb.sheets, &WorkSheet{wb: wb, bs: new(boundsheet)})
+	sheet, err := wb.GetSheetE(0)
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("error is %v", err)
+	}
+	if perr.RecordID != 0x201 || perr.Offset != int64(len(number)) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error is %v", err)
+	}
+	if sheet == nil || sheet.Row(0) == nil {
+		t.Error("rows before the error are lost")
+	}
+	if _, err := wb.GetSheetE(1); err == nil {
+		t.Error("missing sheet has no error")
+	}
+}
+
+func TestOpenReaderNotXls(t *testing.T) {
+	if _, err := OpenReader(bytes.NewReader([]byte("not a xls file")), ""); err != ErrNotXls {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestOpenReaderBrokenChains(t *testing.T) {
+	wb := NewWorkBook()
+	sheet, _ := wb.AddSheet("Sheet1")
+	sheet.SetCell(0, 0, "a")
+	var buf bytes.Buffer
+	if err := wb.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fat := 512 + 512*int(binary.LittleEndian.Uint32(buf.Bytes()[76:]))
+	for name, corrupt := range map[string]func([]byte){
+		// ole2 indexes the allocation table out of range on a sector beyond it
+		"beyond": func(file []byte) { binary.LittleEndian.PutUint32(file[fat:], 0x7FFFFFFF) },
+		// and loops forever on a cyclic chain of the stream or the DIFAT
+		"loop":  func(file []byte) { binary.LittleEndian.PutUint32(file[fat+4:], 0) },
+		"DIFAT": func(file []byte) { binary.LittleEndian.PutUint32(file[68:], 0xFFFFFFFF) },
+	} {
+		file := append([]byte(nil), buf.Bytes()...)
+		corrupt(file)
+		if _, err := OpenReader(bytes.NewReader(file), ""); err == nil {
+			t.Errorf("%s chain is read", name)
+		}
+	}
+}
+
+func TestOpenReaderSeeker(t *testing.T) {
+	file, err := os.ReadFile("Table.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := OpenReader(bytes.NewReader(file), "utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a reader without ReadAt is read at the offsets of the stream by seeking
+	wb, err := OpenReader(struct{ io.ReadSeeker }{bytes.NewReader(file)}, "utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wb.ReadAllCells(100), want.ReadAllCells(100); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("cells are %v instead of %v", got, want)
+	}
+}
+
+func TestRowIterator(t *testing.T) {
+	wb, err := Open("Table.xls", "utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := wb.GetSheet(0)
+	it := sheet.Rows()
+	n := 0
+	for it.Next() {
+		row := it.Row()
+		want := sheet.Row(n)
+		if want == nil || row.FirstCol() != want.FirstCol() || row.LastCol() != want.LastCol() {
+			t.Fatalf("row %d differs", n)
+		}
+		for i := row.FirstCol(); i < row.LastCol(); i++ {
+			if row.Col(i) != want.Col(i) {
+				t.Errorf("row %d col %d is %q instead of %q", n, i, row.Col(i), want.Col(i))
+			}
+		}
+		n++
+	}
+	if it.Err() != nil || n != int(sheet.MaxRow)+1 {
+		t.Errorf("%d rows, %v", n, it.Err())
+	}
+
+	// a sheet without DBCELL records
+	var records [][]byte
+	for i := 0; i < 300; i++ {
+		records = append(records, record(0x203, uint16(i), uint16(0), uint16(0), float64(i)))
+	}
+	// with hyperlinks and merged cells after the cells
+	var guid [16]byte
+	binary.BigEndian.PutUint64(guid[:], 0xE0C9EA79F9BACE11)
+	binary.BigEndian.PutUint64(guid[8:], 0x8C8200AA004BA90B)
+	for _, i := range []uint16{5, 400} {
+		records = append(records, record(0x1b8, i, i, uint16(0), uint16(0), [20]byte{}, uint32(0x15),
+			uint32(3), utf16.Encode([]rune("go\x00")), guid, uint32(18), utf16.Encode([]rune("http://x\x00"))))
+	}
+	records = append(records, record(0xe5, uint16(1), uint16(0), uint16(1), uint16(0), uint16(0)), record(0x0a))
+	wb = &WorkBook{Formats: make(map[uint16]*Format), rs: sectionOf(bytes.Join(records, nil)), opts: Options{FillMergedCells: true}}
+	it = (&WorkSheet{wb: wb, bs: new(boundsheet)}).Rows()
+	for n = 0; it.Next(); n++ {
+		// the merged range of the rows 0 and 1 is not filled
+		if row := it.Row(); row.Col(0) != fmt.Sprint(n) || row.wb != wb || row.sheet == nil {
+			t.Fatalf("row %d is %q", n, row.Col(0))
+		}
+	}
+	if it.Err() != nil || n != 300 {
+		t.Errorf("%d rows, %v", n, it.Err())
+	}
+	if links := it.HyperLinks(); len(links) != 2 || links[1].FirstRowB != 400 || links[1].URL != "http://x" {
+		t.Errorf("hyperlinks are %v", links)
+	}
+}
+
+func TestConcurrentSheets(t *testing.T) {
+	wb, err := Open("Table.xls", "utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := wb.sheets[0].bs
+	for i := 0; i < 7; i++ {
+		wb.sheets = append(wb.sheets, &WorkSheet{bs: bs, wb: wb, Name: fmt.Sprint(i)})
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < wb.NumSheets(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wb.GetSheet(i % 4)
+		}(i)
+	}
+	if err := wb.LoadAllSheets(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	want := wb.sheets[0].Row(5).Col(1)
+	// the rows of a loaded sheet are read from several goroutines
+	for i := 0; i < wb.NumSheets(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if sheet := wb.GetSheet(i); sheet.MaxRow != wb.sheets[0].MaxRow || sheet.Row(5).Col(1) != want {
+				t.Errorf("sheet %d differs", i)
+			}
+			if wb.sheets[0].Row(5).Col(1) != want {
+				t.Errorf("first sheet differs in %d", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	wb.sheets = append(wb.sheets, &WorkSheet{bs: bs, wb: wb})
+	if err := wb.LoadAllSheets(ctx, 1); err != context.Canceled {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestOpenReaderLimits(t *testing.T) {
+	data, err := os.ReadFile("Table.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(ctx context.Context, opts Options) error {
+		_, err := OpenReaderContext(ctx, bytes.NewReader(data), opts)
+		return err
+	}
+	for _, opts := range []Options{{MaxRecords: 10}, {MaxSSTSize: 1}, {MaxStringLength: 2}} {
+		if err := open(context.Background(), opts); !errors.Is(err, ErrLimit) {
+			t.Errorf("%+v: error is %v", opts, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := open(ctx, Options{}); err != context.Canceled {
+		t.Errorf("error is %v", err)
+	}
+
+	wb, err := OpenReaderContext(context.Background(), bytes.NewReader(data), Options{MaxCellsPerSheet: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wb.GetSheetE(0); !errors.Is(err, ErrLimit) {
+		t.Errorf("error is %v", err)
+	}
+	wb, _ = OpenReaderContext(context.Background(), bytes.NewReader(data), Options{MaxCellsPerSheet: 1000})
+	if _, err := wb.GetSheetContext(ctx, 0); err != context.Canceled {
+		t.Errorf("error is %v", err)
+	}
+	if sheet, err := wb.GetSheetE(0); err != nil || sheet.Row(0) == nil {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestWriteWorkBook(t *testing.T) {
+	wb := NewWorkBook()
+	sheet, err := wb.AddSheet("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wb.AddSheet("data"); err == nil {
+		t.Error("duplicate sheet name accepted")
+	}
+	if _, err := wb.AddSheet("a/b"); err == nil {
+		t.Error("invalid sheet name accepted")
+	}
+	date := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	stamp := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	long := strings.Repeat("长", 5000)
+	values := []interface{}{"text", 42, -1.25, 1e300, true, date, stamp, long, -5, -1, -(1 << 29), 123456789012}
+	for i, v := range values {
+		if err := sheet.SetCell(0, i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// enough strings and rows for CONTINUE records and several row blocks
+	for i := 1; i < 3000; i++ {
+		if err := sheet.SetCell(i, 2, fmt.Sprintf("row %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sheet.SetCell(0, 256, 1); err == nil {
+		t.Error("column 256 accepted")
+	}
+	if err := sheet.SetCell(0, 0, math.NaN()); err == nil {
+		t.Error("NaN accepted")
+	}
+	other, _ := wb.AddSheet("Other")
+	other.SetCell(5, 1, "text")
+
+	rows := 0
+	for it := sheet.Rows(); it.Next(); rows++ {
+	}
+	if rows != 3000 {
+		t.Errorf("iterated %d rows", rows)
+	}
+
+	var buf bytes.Buffer
+	if err := wb.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := OpenReader(bytes.NewReader(buf.Bytes()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.NumSheets() != 2 {
+		t.Fatalf("%d sheets", read.NumSheets())
+	}
+	got, err := read.GetSheetE(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Data" || got.MaxRow != 2999 {
+		t.Errorf("sheet %q with %d rows", got.Name, got.MaxRow)
+	}
+	row := got.Row(0)
+	if s := row.Cell(0).String(); s != "text" {
+		t.Errorf("col 0 is %q", s)
+	}
+	for i, want := range []float64{42, -1.25, 1e300} {
+		if f, err := row.Cell(i + 1).Float(); err != nil || f != want {
+			t.Errorf("col %d is %v, %v", i+1, f, err)
+		}
+	}
+	if b, err := row.Cell(4).Bool(); err != nil || !b {
+		t.Errorf("col 4 is %v, %v", b, err)
+	}
+	for i, want := range []time.Time{date, stamp} {
+		if tm, err := row.Cell(i + 5).Time(); err != nil || !tm.Round(time.Millisecond).Equal(want) {
+			t.Errorf("col %d is %v, %v", i+5, tm, err)
+		}
+	}
+	if s := row.Cell(7).String(); s != long {
+		t.Errorf("long string of %d chars", len([]rune(s)))
+	}
+	// a number in General format keeps its digits, Excel shows 11 characters
+	if s, c := row.Col(11), row.Cell(11); s != "123456789012" || c.String() != s || c.Text() != "1.23457E+11" {
+		t.Errorf("col 11 is %q, cell %q shown as %q", s, c.String(), c.Text())
+	}
+	// negative integers are written as RK records
+	for i, want := range []string{"-5", "-1", "-536870912"} {
+		if s := row.Col(i + 8); s != want {
+			t.Errorf("col %d is %q", i+8, s)
+		}
+	}
+	if s := got.Row(2999).Col(2); s != "row 2999" {
+		t.Errorf("row 2999 is %q", s)
+	}
+	if s := read.GetSheet(1).Row(5).Col(1); s != "text" {
+		t.Errorf("second sheet has %q", s)
+	}
+}